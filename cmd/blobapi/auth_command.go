@@ -0,0 +1,143 @@
+package blobapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+import (
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+	"github.com/Eagerod/blobstore-client/pkg/config"
+	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
+)
+
+// Keeps just enough of a secret to tell tokens apart.
+func redact(value string) string {
+	if value == "" {
+		return "<empty>"
+	}
+
+	if len(value) < 12 {
+		return "****"
+	}
+
+	return "****" + value[len(value)-4:]
+}
+
+func newAuthCommand(apiClient *blob.BlobStoreApiClient, options *globalOptions) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "auth",
+		Short: "Inspect credentials",
+		Long:  "Inspect the credentials used to talk to the blobstore",
+	}
+
+	var method string
+	var probe bool
+	var probeWrite bool
+
+	explainCommand := &cobra.Command{
+		Use:   "explain [BlobPath]",
+		Short: "Show where credentials come from",
+		Long:  "Show which credential provider supplied each header of a request, with values redacted",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "/"
+			if len(args) == 1 {
+				explainArg, err := newBlobParsedArg(args[0])
+				if err != nil {
+					return err
+				}
+
+				if explainArg.Scheme != BlobStoreUrlScheme {
+					return errors.New("Must start auth explain path with blob:/")
+				}
+
+				path = explainArg.Path
+			}
+
+			method = strings.ToUpper(method)
+			switch method {
+			case "GET", "HEAD", "POST", "DELETE":
+			default:
+				return fmt.Errorf("Unsupported method %s; must be one of GET, HEAD, POST, DELETE", method)
+			}
+
+			sources, err := apiClient.ExplainAuthorization(cmd.Context(), method, path)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Endpoint: %s\n", apiClient.BaseUrl())
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile: %s\n", config.SelectProfileName(options.profile))
+			fmt.Fprintf(cmd.OutOrStdout(), "Request: %s %s\n", method, path)
+
+			found := map[string]bool{}
+			for _, source := range sources {
+				found[source.Header] = true
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %s (from %s)\n", source.Header, redact(source.Value), source.Provider)
+			}
+
+			requiredHeader := credential_provider.HttpRequestWriteAclHeader
+			if credential_provider.RequiresReadAcl(&http.Request{Method: method}) {
+				requiredHeader = credential_provider.HttpRequestReadAclHeader
+			}
+
+			if !found[http.CanonicalHeaderKey(requiredHeader)] {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: not set\n", http.CanonicalHeaderKey(requiredHeader))
+			}
+
+			if probe {
+				stat, err := apiClient.GetStatContext(cmd.Context(), path)
+				if err != nil {
+					if errors.Is(err, blob.ErrUnauthorized) || errors.Is(err, blob.ErrForbidden) {
+						fmt.Fprintln(cmd.OutOrStdout(), "Probe: server rejected read credentials")
+					}
+					return err
+				}
+
+				// The server may look for the file before it checks the
+				// credentials, so a missing file doesn't prove anything.
+				if !stat.Exists {
+					fmt.Fprintf(cmd.OutOrStdout(), "Probe: %s does not exist, so the read credentials could not be checked\n", path)
+					return &blob.BlobStoreHttpError{Operation: "Probe", Path: path, StatusCode: http.StatusNotFound}
+				}
+
+				fmt.Fprintln(cmd.OutOrStdout(), "Probe: server accepted read credentials")
+			}
+
+			if probeWrite {
+				probePath := strings.TrimSuffix(path, "/") + "/.blobapi-probe-" + uuid.New().String()
+				if err := apiClient.UploadStreamContext(cmd.Context(), probePath, bufio.NewReader(strings.NewReader("")), "text/plain"); err != nil {
+					if errors.Is(err, blob.ErrUnauthorized) || errors.Is(err, blob.ErrForbidden) {
+						fmt.Fprintln(cmd.OutOrStdout(), "Probe: server rejected write credentials")
+					}
+					return err
+				}
+
+				if err := apiClient.DeleteFileContext(cmd.Context(), probePath); err != nil {
+					return err
+				}
+
+				fmt.Fprintln(cmd.OutOrStdout(), "Probe: server accepted write credentials")
+			}
+
+			return nil
+		},
+	}
+
+	explainCommand.Flags().StringVarP(&method, "method", "X", "HEAD", "HTTP method of the request to explain")
+	explainCommand.Flags().BoolVar(&probe, "probe", false, "Send a HEAD request to check whether the server accepts the read credentials")
+	explainCommand.Flags().BoolVar(&probeWrite, "probe-write", false, "Upload and then delete a temporary file beside the path to check whether the server accepts the write credentials")
+
+	command.AddCommand(explainCommand)
+
+	return command
+}
@@ -1,7 +1,16 @@
 package blobapi
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
 import (
@@ -10,10 +19,12 @@ import (
 
 import (
 	"github.com/Eagerod/blobstore-client/pkg/blob"
+	"github.com/Eagerod/blobstore-client/pkg/config"
 	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
 )
 
 const BlobStoreDefaultUrlBase = "https://blob.aleemhaji.com"
+const BlobStoreUrlEnvironmentVariable = "BLOBSTORE_URL"
 
 type blobParsedArg struct {
 	isRemote bool
@@ -22,26 +33,246 @@ type blobParsedArg struct {
 
 const BlobStoreUrlScheme string = "blob"
 
+// Paths are kept exactly as they were given, rather than parsed as URLs, so a
+// ? or # in a key or file name isn't mistaken for a query or fragment.
 func newBlobParsedArg(arg string) (*url.URL, error) {
-	return url.Parse(arg)
+	if strings.HasPrefix(arg, BlobStoreUrlScheme+":") {
+		return &url.URL{Scheme: BlobStoreUrlScheme, Path: strings.TrimPrefix(arg, BlobStoreUrlScheme+":")}, nil
+	}
+
+	return &url.URL{Path: arg}, nil
+}
+
+const DefaultRetries int = 3
+
+// Settings are taken from, in order of precedence:
+//  1. Command line flags
+//  2. Environment variables
+//  3. The selected profile in the config file
+//  4. Built-in defaults
+type globalOptions struct {
+	profile  string
+	endpoint string
+	timeout  time.Duration
+	retries  int
+	verbose  bool
+	output   string
+	parallel int
+	failFast bool
+
+	noProgress       bool
+	progressInterval time.Duration
+
+	outputFormat *outputFormat
+}
+
+// Credentials are kept beside the config file.
+func defaultCredentialsPath() (string, error) {
+	dir, err := config.DefaultConfigDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, credential_provider.CredentialsFileName), nil
+}
+
+func newCredentialProvider(profile *config.Profile, credentialsPath string) credential_provider.ICredentialProvider {
+	switch profile.Credentials {
+	case config.CredentialsEnvironment:
+		return &credential_provider.EnvironmentCredentialProvider{
+			ReadAclEnvironmentVariable:  credential_provider.DefaultBlobStoreReadAclEnvironmentVariable,
+			WriteAclEnvironmentVariable: credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable,
+		}
+	case config.CredentialsFile:
+		return &credential_provider.FileCredentialProvider{Path: credentialsPath, Profile: profile.Name}
+	case config.CredentialsExec:
+		return credential_provider.NewExecCredentialProvider(profile.CredentialsCommand...)
+	case config.CredentialsNone:
+		return &credential_provider.DirectCredentialProvider{}
+	default:
+		return credential_provider.NewDefaultCredentialProviderChain(credentialsPath, profile.Name)
+	}
+}
+
+func loadConfig() (*config.Config, error) {
+	path, err := config.DefaultConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Load(path)
+}
+
+// Blobs under any of the profile's credentials.<prefix> settings use the
+// credentials of the profile named by the setting instead.
+func newProfileCredentialProvider(cfg *config.Config, profile *config.Profile, baseUrl string) (credential_provider.ICredentialProvider, error) {
+	credentialsPath, err := defaultCredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	provider := newCredentialProvider(profile, credentialsPath)
+	if len(profile.PrefixCredentials) == 0 {
+		return provider, nil
+	}
+
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := map[string]credential_provider.ICredentialProvider{}
+	for prefix, name := range profile.PrefixCredentials {
+		prefixProfile, err := loadProfile(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes[prefix] = newCredentialProvider(prefixProfile, credentialsPath)
+	}
+
+	return &credential_provider.PrefixCredentialProvider{
+		BasePath: base.Path,
+		Prefixes: prefixes,
+		Default:  provider,
+	}, nil
+}
+
+// Profiles that only hold credentials don't need to appear in the config
+// file too.
+func loadProfile(cfg *config.Config, name string) (*config.Profile, error) {
+	profile, err := cfg.Profile(name)
+	if !errors.Is(err, config.ErrProfileNotFound) {
+		return profile, err
+	}
+
+	credentialsPath, credentialsErr := defaultCredentialsPath()
+	if credentialsErr != nil {
+		return nil, credentialsErr
+	}
+
+	if ok, credentialsErr := credential_provider.CredentialsFileHasProfile(credentialsPath, name); credentialsErr != nil {
+		return nil, credentialsErr
+	} else if !ok {
+		return nil, err
+	}
+
+	return &config.Profile{Name: name}, nil
+}
+
+func configureApiClient(cmd *cobra.Command, apiClient *blob.BlobStoreApiClient, options *globalOptions) error {
+	if options.retries < 0 {
+		return errors.New("Cannot retry a negative number of times")
+	}
+
+	if options.timeout < 0 {
+		return errors.New("Cannot use a negative timeout")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile, err := loadProfile(cfg, config.SelectProfileName(options.profile))
+	if err != nil {
+		return err
+	}
+
+	urlBase := BlobStoreDefaultUrlBase
+	if profile.Endpoint != "" {
+		urlBase = profile.Endpoint
+	}
+	if envUrlBase := os.Getenv(BlobStoreUrlEnvironmentVariable); envUrlBase != "" {
+		urlBase = envUrlBase
+	}
+	if options.endpoint != "" {
+		urlBase = options.endpoint
+	}
+	apiClient.SetBaseUrl(urlBase)
+
+	credentialProvider, err := newProfileCredentialProvider(cfg, profile, apiClient.BaseUrl())
+	if err != nil {
+		return err
+	}
+	apiClient.SetCredentialProvider(credentialProvider)
+
+	if cmd.Flags().Changed("timeout") {
+		apiClient.SetTimeout(options.timeout)
+	} else if profile.Timeout != 0 {
+		apiClient.SetTimeout(profile.Timeout)
+	}
+
+	retries := options.retries
+	if !cmd.Flags().Changed("retries") && profile.Retries != nil {
+		retries = *profile.Retries
+	}
+	apiClient.SetRetryPolicy(blob.NewRetryPolicy(retries))
+
+	if options.verbose {
+		apiClient.SetLogger(log.New(os.Stderr, "", log.LstdFlags))
+	}
+
+	return nil
 }
 
 func Execute() error {
-	var b blob.IBlobStoreClient = blob.NewBlobStoreClient(
+	options := &globalOptions{}
+
+	apiClient := blob.NewBlobStoreApiClient(
 		BlobStoreDefaultUrlBase,
 		credential_provider.DefaultCredentialProviderChain(),
 	)
 
+	client := blob.NewBlobStoreClientWithApiClient(apiClient)
+	var b blob.IBlobStoreClient = client
+
 	baseCommand := &cobra.Command{
 		Use:   "blob",
 		Short: "Blobstore CLI",
 		Long:  "Download, upload or append data to the blobstore",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			outputFormat, err := parseOutputFormat(options.output)
+			if err != nil {
+				return err
+			}
+			options.outputFormat = outputFormat
+
+			if options.parallel < 1 {
+				return errors.New("--parallel must be at least 1")
+			}
+			client.SetTransferOptions(blob.TransferOptions{Parallel: options.parallel, FailFast: options.failFast})
+			apiClient.SetMaxIdleConnections(options.parallel)
+
+			return configureApiClient(cmd, apiClient, options)
+		},
 	}
 
-	baseCommand.AddCommand(newCpCommand(b))
+	baseCommand.PersistentFlags().StringVar(&options.profile, "profile", "", "Profile to use from the config file (default $"+config.ProfileEnvironmentVariable+", or \""+config.DefaultProfileName+"\")")
+	baseCommand.PersistentFlags().StringVar(&options.endpoint, "endpoint", "", "Blobstore URL, overriding $"+BlobStoreUrlEnvironmentVariable+" and the profile's endpoint")
+	baseCommand.PersistentFlags().DurationVar(&options.timeout, "timeout", 0, "Timeout for each request, overriding the profile's timeout (default 30s)")
+	baseCommand.PersistentFlags().IntVar(&options.retries, "retries", DefaultRetries, "Number of times to retry requests that fail transiently")
+	baseCommand.PersistentFlags().BoolVarP(&options.verbose, "verbose", "v", false, "Log additional detail about requests to stderr")
+	baseCommand.PersistentFlags().IntVar(&options.parallel, "parallel", blob.DefaultTransferParallelism, "Number of files to transfer at once in operations on many files")
+	baseCommand.PersistentFlags().BoolVar(&options.failFast, "fail-fast", false, "Stop operations on many files at the first failure, rather than trying every file")
+	baseCommand.PersistentFlags().BoolVar(&options.noProgress, "no-progress", false, "Don't report the progress of uploads and downloads on stderr")
+	baseCommand.PersistentFlags().DurationVar(&options.progressInterval, "progress-interval", DefaultProgressInterval, "How often to log progress when stderr isn't a terminal")
+	baseCommand.PersistentFlags().StringVar(&options.output, "output", OutputText, "Output format: text, json, ndjson, csv, or template=<Go template>")
+
+	baseCommand.AddCommand(newCpCommand(b, options))
+	baseCommand.AddCommand(newMvCommand(b, options))
 	baseCommand.AddCommand(newAppendCommand(b))
-	baseCommand.AddCommand(newLsCommand(b))
-	baseCommand.AddCommand(newRmCommand(b))
+	baseCommand.AddCommand(newLsCommand(b, options))
+	baseCommand.AddCommand(newStatCommand(b, options))
+	baseCommand.AddCommand(newRmCommand(b, options))
+	baseCommand.AddCommand(newSyncCommand(b, options))
+	baseCommand.AddCommand(newConfigCommand(&options.profile))
+	baseCommand.AddCommand(newAuthCommand(apiClient, options))
+
+	// Interrupting the process cancels any in-flight requests, rather than
+	// killing it outright part way through writing a file.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	return baseCommand.Execute()
+	return baseCommand.ExecuteContext(ctx)
 }
@@ -0,0 +1,91 @@
+package blobapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/config"
+)
+
+func newConfigCommand(profileFlag *string) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Manage CLI configuration",
+		Long:  "Get or set values in the selected profile of the config file\n\nKeys: " + strings.Join(config.Keys, ", "),
+		// Skip loading the selected profile, so that an invalid config file
+		// can still be fixed up from here.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:   "get <Key>",
+		Short: "Print a config value",
+		Long:  "Print a value from the selected profile of the config file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			profile := config.SelectProfileName(*profileFlag)
+			value, ok := cfg.Get(profile, args[0])
+			if !ok {
+				return fmt.Errorf("%s is not set in profile %s", args[0], profile)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	})
+
+	command.AddCommand(&cobra.Command{
+		Use:   "set <Key> <Value>",
+		Short: "Set a config value",
+		Long:  "Set a value in the selected profile of the config file, creating the profile if needed",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if err := cfg.Set(config.SelectProfileName(*profileFlag), args[0], args[1]); err != nil {
+				return err
+			}
+
+			return cfg.Save()
+		},
+	})
+
+	command.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List config values",
+		Long:  "List all values set in the selected profile of the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			profile := config.SelectProfileName(*profileFlag)
+			for _, key := range cfg.List(profile) {
+				value, _ := cfg.Get(profile, key)
+				fmt.Fprintf(cmd.OutOrStdout(), "%s = %s\n", key, value)
+			}
+
+			return nil
+		},
+	})
+
+	return command
+}
@@ -1,5 +1,15 @@
 package blobapi
 
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
 import (
 	"github.com/spf13/cobra"
 )
@@ -9,9 +19,12 @@ import (
 )
 
 
-func newCpCommand(client blob.IBlobStoreClient) *cobra.Command {
+func newCpCommand(client blob.IBlobStoreClient, options *globalOptions) *cobra.Command {
 	var contentType string
 	var force bool
+	var resume bool
+	var recursive bool
+	var noGlob bool
 
 	command := &cobra.Command{
 		Use:   "cp <LocalPath> <BlobPath> or <BlobPath> <LocalPath>",
@@ -25,7 +38,18 @@ func newCpCommand(client blob.IBlobStoreClient) *cobra.Command {
 			}
 
 			if len(args) == 1 {
-				return client.Cat(cpArg0)
+				catArgs, err := expandBlobArg(cmd.Context(), client, cpArg0, noGlob)
+				if err != nil {
+					return err
+				}
+
+				for _, catArg := range catArgs {
+					if err := client.CatContext(cmd.Context(), catArg); err != nil {
+						return err
+					}
+				}
+
+				return nil
 			}
 
 			cpArg1, err := newBlobParsedArg(args[1])
@@ -33,12 +57,98 @@ func newCpCommand(client blob.IBlobStoreClient) *cobra.Command {
 				return err
 			}
 
-			return client.Copy(cpArg0, cpArg1, force)
+			if isBlobGlob(cpArg1, noGlob) {
+				return errors.New("Destination can't contain glob characters; use --no-glob to copy to a path containing them")
+			}
+
+			ctx, finishProgress := startProgress(cmd, options)
+			defer finishProgress()
+
+			if isBlobGlob(cpArg0, noGlob) {
+				if recursive || resume {
+					return errors.New("Can't copy files matching a glob recursively or continue downloading them")
+				}
+
+				summary, err := copyBlobGlob(ctx, client, cpArg0, cpArg1, force)
+				finishProgress()
+				return reportTransferSummary(cmd, options.outputFormat, "Copied", summary, err)
+			}
+
+			if recursive {
+				if cpArg0.Scheme == BlobStoreUrlScheme && cpArg1.Scheme == BlobStoreUrlScheme {
+					summary, err := client.CopyPrefixContext(ctx, cpArg0, cpArg1, force)
+					finishProgress()
+					return reportTransferSummary(cmd, options.outputFormat, "Copied", summary, err)
+				}
+
+				if cpArg0.Scheme == BlobStoreUrlScheme && cpArg1.Scheme != BlobStoreUrlScheme {
+					summary, err := client.DownloadPrefixContext(ctx, cpArg0, cpArg1.Path, force)
+					finishProgress()
+					return reportTransferSummary(cmd, options.outputFormat, "Downloaded", summary, err)
+				}
+
+				if cpArg1.Scheme != BlobStoreUrlScheme {
+					return errors.New("Must provide at least one blob:/ path to upload to or download from")
+				}
+
+				summary, err := client.UploadDirectoryContext(ctx, cpArg1, cpArg0.Path, force)
+				finishProgress()
+				return reportTransferSummary(cmd, options.outputFormat, "Uploaded", summary, err)
+			}
+
+			if resume {
+				return client.CopyResumableContext(ctx, cpArg0, cpArg1, force)
+			}
+
+			return client.CopyContext(ctx, cpArg0, cpArg1, force)
 		},
 	}
 
 	command.Flags().StringVarP(&contentType, "type", "t", "", "Content type of uploaded file")
 	command.Flags().BoolVarP(&force, "force", "f", false, "Force the copy if the destination already exists")
+	command.Flags().BoolVarP(&resume, "continue", "c", false, "Continue a previously interrupted download")
+	command.Flags().BoolVarP(&recursive, "recursive", "r", false, "Copy a directory and everything in it")
+	addNoGlobFlag(command, &noGlob)
 
 	return command
-}
\ No newline at end of file
+}
+
+// Copies every file matching the pattern in src into the directory or prefix
+// dst, keeping their paths below the part of the pattern without any glob
+// characters, so files with the same name in different directories don't
+// end up in the same place.
+func copyBlobGlob(ctx context.Context, client blob.IBlobStoreClient, src *url.URL, dst *url.URL, force bool) (*blob.TransferSummary, error) {
+	srcFiles, err := expandBlobArg(ctx, client, src, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if dst.Scheme != BlobStoreUrlScheme {
+		if err := os.MkdirAll(dst.Path, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	prefix, _ := blob.GlobPrefix(src.Path)
+
+	jobs := make([]blob.TransferJob, 0, len(srcFiles))
+	for _, srcFile := range srcFiles {
+		relativePath := strings.TrimPrefix(strings.TrimLeft(srcFile.Path, "/"), prefix)
+
+		dstFile := &url.URL{Scheme: dst.Scheme}
+		if dst.Scheme == BlobStoreUrlScheme {
+			dstFile.Path = path.Join(dst.Path, relativePath)
+		} else {
+			dstFile.Path = filepath.Join(dst.Path, filepath.FromSlash(relativePath))
+		}
+
+		jobs = append(jobs, blob.TransferJob{
+			Kind:        blob.TransferCopy,
+			Source:      srcFile,
+			Destination: dstFile,
+			Force:       force,
+		})
+	}
+
+	return client.TransferContext(ctx, jobs)
+}
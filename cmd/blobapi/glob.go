@@ -0,0 +1,52 @@
+package blobapi
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+const noGlobFlagUsage string = "Treat *, ? and [ in blob:/ paths literally"
+
+func addNoGlobFlag(command *cobra.Command, noGlob *bool) {
+	command.Flags().BoolVar(noGlob, "no-glob", false, noGlobFlagUsage)
+}
+
+func isBlobGlob(arg *url.URL, noGlob bool) bool {
+	return arg.Scheme == BlobStoreUrlScheme && !noGlob && blob.HasGlobMeta(arg.Path)
+}
+
+func blobKeyUrl(key string) *url.URL {
+	return &url.URL{
+		Scheme: BlobStoreUrlScheme,
+		Path:   "/" + strings.TrimLeft(key, "/"),
+	}
+}
+
+// Replaces a blob:/ path containing glob characters with every file that
+// matches it. Any other path is returned as it is.
+func expandBlobArg(ctx context.Context, client blob.IBlobStoreClient, arg *url.URL, noGlob bool) ([]*url.URL, error) {
+	if !isBlobGlob(arg, noGlob) {
+		return []*url.URL{arg}, nil
+	}
+
+	keys, err := client.GlobContext(ctx, arg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := make([]*url.URL, 0, len(keys))
+	for _, key := range keys {
+		rv = append(rv, blobKeyUrl(key))
+	}
+
+	return rv, nil
+}
@@ -3,6 +3,11 @@ package blobapi
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 import (
@@ -13,8 +18,21 @@ import (
 	"github.com/Eagerod/blobstore-client/pkg/blob"
 )
 
-func newLsCommand(client blob.IBlobStoreClient) *cobra.Command {
+const (
+	LsSortName string = "name"
+	LsSortSize string = "size"
+	LsSortTime string = "time"
+)
+
+const lsTimeFormat string = "2006-01-02 15:04:05"
+
+func newLsCommand(client blob.IBlobStoreClient, options *globalOptions) *cobra.Command {
 	var recursive bool
+	var long bool
+	var humanReadable bool
+	var sortBy string
+	var reverse bool
+	var noGlob bool
 
 	command := &cobra.Command{
 		Use:   "ls [BlobPath]",
@@ -23,6 +41,7 @@ func newLsCommand(client blob.IBlobStoreClient) *cobra.Command {
 		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			prefix := ""
+			pattern := ""
 
 			if len(args) == 1 {
 				lsArg, err := newBlobParsedArg(args[0])
@@ -35,22 +54,181 @@ func newLsCommand(client blob.IBlobStoreClient) *cobra.Command {
 				}
 
 				prefix = lsArg.Path
+				if isBlobGlob(lsArg, noGlob) {
+					// With -r, everything below a matching directory is
+					// listed too.
+					pattern = lsArg.Path
+					if recursive {
+						pattern = strings.TrimSuffix(pattern, "/") + "/**"
+					}
+
+					var patternRecursive bool
+					prefix, patternRecursive = blob.GlobPrefix(pattern)
+					recursive = recursive || patternRecursive
+				}
+			}
+
+			if sortBy != LsSortName && sortBy != LsSortSize && sortBy != LsSortTime {
+				return fmt.Errorf("Unknown sort order %s; must be one of %s, %s, or %s", sortBy, LsSortName, LsSortSize, LsSortTime)
 			}
 
-			files, err := client.ListPrefix(prefix, recursive)
+			// Sorting by name alone doesn't need any details of the files.
+			if !long && sortBy == LsSortName && options.outputFormat.isText() {
+				files, err := client.ListPrefixContext(cmd.Context(), prefix, recursive)
+				if err != nil {
+					return err
+				}
+
+				if pattern != "" {
+					if files, err = blob.MatchingKeys(pattern, files); err != nil {
+						return err
+					}
+				}
+
+				if reverse {
+					sort.Sort(sort.Reverse(sort.StringSlice(files)))
+				}
+
+				for i := range files {
+					fmt.Println(files[i])
+				}
+
+				return nil
+			}
+
+			stats, err := client.ListPrefixStatContext(cmd.Context(), prefix, recursive)
 			if err != nil {
 				return err
 			}
 
-			for i := range files {
-				fmt.Println(files[i])
+			if pattern != "" {
+				if stats, err = blob.MatchingFileStats(pattern, stats); err != nil {
+					return err
+				}
+			}
+
+			sortFileStats(stats, sortBy, reverse)
+
+			if !options.outputFormat.isText() {
+				return options.outputFormat.writeRecords(cmd.OutOrStdout(), fileStatCsvHeader, fileStatRecords(stats))
 			}
 
-			return nil
+			if !long {
+				for _, stat := range stats {
+					fmt.Fprintln(cmd.OutOrStdout(), listedPath(stat))
+				}
+				return nil
+			}
+
+			sizes := make([]string, len(stats))
+			sizeWidth := 0
+			for i, stat := range stats {
+				sizes[i] = formatListedSize(stat, humanReadable)
+				if len(sizes[i]) > sizeWidth {
+					sizeWidth = len(sizes[i])
+				}
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			for i, stat := range stats {
+				fmt.Fprintf(w, "%*s\t%s\t%s\t%s\n", sizeWidth, sizes[i], formatListedValue(stat.MimeType), formatListedTime(stat.LastModified), listedPath(stat))
+			}
+			return w.Flush()
 		},
 	}
 
+	// -h is used for human readable sizes, like ls, so help is only available
+	// through its long form.
+	command.Flags().Bool("help", false, "help for ls")
+
 	command.Flags().BoolVarP(&recursive, "recursive", "r", false, "List all files and folders recursively")
+	command.Flags().BoolVarP(&long, "long", "l", false, "Print the size, type and modification time of each file")
+	command.Flags().BoolVarP(&humanReadable, "human-readable", "h", false, "Print sizes in powers of 1024, like 4.2M")
+	command.Flags().StringVar(&sortBy, "sort", LsSortName, "Sort files by name, size, or time")
+	command.Flags().BoolVar(&reverse, "reverse", false, "Reverse the sort order")
+	addNoGlobFlag(command, &noGlob)
 
 	return command
-}
\ No newline at end of file
+}
+
+func listedPath(stat blob.BlobFileStat) string {
+	return strings.TrimPrefix(stat.Path, "/") + stat.Name
+}
+
+// Largest files and most recently modified files come first, like ls. Ties
+// are broken by name so the output is stable.
+func sortFileStats(stats []blob.BlobFileStat, sortBy string, reverse bool) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if reverse {
+			a, b = b, a
+		}
+
+		switch sortBy {
+		case LsSortSize:
+			if a.SizeBytes != b.SizeBytes {
+				return a.SizeBytes > b.SizeBytes
+			}
+		case LsSortTime:
+			if !a.LastModified.Equal(b.LastModified) {
+				return a.LastModified.After(b.LastModified)
+			}
+		}
+
+		return listedPath(a) < listedPath(b)
+	})
+}
+
+func isListedDirectory(stat blob.BlobFileStat) bool {
+	return strings.HasSuffix(stat.Name, "/")
+}
+
+func formatListedSize(stat blob.BlobFileStat, humanReadable bool) string {
+	if isListedDirectory(stat) {
+		return "-"
+	}
+
+	if humanReadable {
+		return formatHumanSize(int64(stat.SizeBytes))
+	}
+
+	return strconv.Itoa(stat.SizeBytes)
+}
+
+func formatListedValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func formatListedTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.UTC().Format(lsTimeFormat)
+}
+
+// Whole bytes below 1K, then one decimal place for anything smaller than 10
+// of a unit, like 4.2M or 512K.
+func formatHumanSize(size int64) string {
+	if size < 1024 {
+		return strconv.FormatInt(size, 10)
+	}
+
+	units := "KMGTPE"
+	value := float64(size)
+	unit := -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if value < 10 {
+		return fmt.Sprintf("%.1f%c", value, units[unit])
+	}
+
+	return fmt.Sprintf("%.0f%c", value, units[unit])
+}
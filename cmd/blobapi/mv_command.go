@@ -0,0 +1,48 @@
+package blobapi
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+func newMvCommand(client blob.IBlobStoreClient, options *globalOptions) *cobra.Command {
+	var force bool
+	var recursive bool
+
+	command := &cobra.Command{
+		Use:   "mv <Source> <Destination>",
+		Short: "Move files to, from, or within blobstore",
+		Long:  "Copy files to, from, or within the blobstore, removing the source once the copy is verified",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mvArg0, err := newBlobParsedArg(args[0])
+			if err != nil {
+				return err
+			}
+
+			mvArg1, err := newBlobParsedArg(args[1])
+			if err != nil {
+				return err
+			}
+
+			ctx, finishProgress := startProgress(cmd, options)
+			defer finishProgress()
+
+			if !recursive {
+				return client.MoveContext(ctx, mvArg0, mvArg1, force)
+			}
+
+			summary, err := client.MoveRecursiveContext(ctx, mvArg0, mvArg1, force)
+			finishProgress()
+			return reportTransferSummary(cmd, options.outputFormat, "Moved", summary, err)
+		},
+	}
+
+	command.Flags().BoolVarP(&force, "force", "f", false, "Force the move if the destination already exists")
+	command.Flags().BoolVarP(&recursive, "recursive", "r", false, "Move a directory or prefix and everything in it")
+
+	return command
+}
@@ -0,0 +1,157 @@
+package blobapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+const (
+	OutputText     string = "text"
+	OutputJson     string = "json"
+	OutputNdjson   string = "ndjson"
+	OutputCsv      string = "csv"
+	OutputTemplate string = "template"
+)
+
+// Anything printed with --output other than text. Each record is one file,
+// so every format can be produced from the same list.
+type outputRecord interface {
+	csvRow() []string
+}
+
+type outputFormat struct {
+	kind     string
+	template *template.Template
+}
+
+// Accepts text, json, ndjson, csv, or template=<Go template>, where the
+// template is executed once for each record.
+func parseOutputFormat(value string) (*outputFormat, error) {
+	switch value {
+	case OutputText, OutputJson, OutputNdjson, OutputCsv:
+		return &outputFormat{kind: value}, nil
+	}
+
+	if strings.HasPrefix(value, OutputTemplate+"=") {
+		t, err := template.New("output").Parse(strings.TrimPrefix(value, OutputTemplate+"="))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid output template: %s", err)
+		}
+
+		return &outputFormat{kind: OutputTemplate, template: t}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown output format %s; must be one of text, json, ndjson, csv, or template=<template>", value)
+}
+
+func (o *outputFormat) isText() bool {
+	return o == nil || o.kind == OutputText
+}
+
+// The header is only used for csv, and is written even if there are no
+// records.
+func (o *outputFormat) writeRecords(w io.Writer, header []string, records []outputRecord) error {
+	switch o.kind {
+	case OutputJson:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case OutputNdjson:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputCsv:
+		writer := csv.NewWriter(w)
+		writer.Write(header)
+		for _, record := range records {
+			writer.Write(record.csvRow())
+		}
+		writer.Flush()
+		return writer.Error()
+	case OutputTemplate:
+		for _, record := range records {
+			if err := o.template.Execute(w, record); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Can't write records as %s", o.kind)
+}
+
+type fileStatRecord blob.BlobFileStat
+
+var fileStatCsvHeader []string = []string{"Path", "Name", "MimeType", "SizeBytes", "Exists", "ETag", "LastModified", "Metadata"}
+
+func (r fileStatRecord) csvRow() []string {
+	lastModified := ""
+	if !r.LastModified.IsZero() {
+		lastModified = r.LastModified.UTC().Format(time.RFC3339)
+	}
+
+	// Metadata is flattened into key=value pairs, in a stable order.
+	metadata := make([]string, 0, len(r.Metadata))
+	for key, value := range r.Metadata {
+		metadata = append(metadata, key+"="+value)
+	}
+	sort.Strings(metadata)
+
+	return []string{r.Path, r.Name, r.MimeType, strconv.Itoa(r.SizeBytes), strconv.FormatBool(r.Exists), r.ETag, lastModified, strings.Join(metadata, ";")}
+}
+
+func fileStatRecords(stats []blob.BlobFileStat) []outputRecord {
+	records := make([]outputRecord, 0, len(stats))
+	for _, stat := range stats {
+		records = append(records, fileStatRecord(stat))
+	}
+	return records
+}
+
+const (
+	TransferStatusTransferred string = "transferred"
+	TransferStatusSkipped     string = "skipped"
+	TransferStatusFailed      string = "failed"
+)
+
+type transferRecord struct {
+	Path   string `json:"Path"`
+	Status string `json:"Status"`
+	Error  string `json:"Error,omitempty"`
+}
+
+var transferCsvHeader []string = []string{"Path", "Status", "Error"}
+
+func (r transferRecord) csvRow() []string {
+	return []string{r.Path, r.Status, r.Error}
+}
+
+func transferRecords(summary *blob.TransferSummary) []outputRecord {
+	records := make([]outputRecord, 0, summary.Total())
+	for _, p := range summary.Transferred {
+		records = append(records, transferRecord{Path: p, Status: TransferStatusTransferred})
+	}
+	for _, p := range summary.Skipped {
+		records = append(records, transferRecord{Path: p, Status: TransferStatusSkipped})
+	}
+	for _, failure := range summary.Failed {
+		records = append(records, transferRecord{Path: failure.Path, Status: TransferStatusFailed, Error: failure.Err.Error()})
+	}
+	return records
+}
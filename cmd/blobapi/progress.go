@@ -0,0 +1,205 @@
+package blobapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+const DefaultProgressInterval time.Duration = 5 * time.Second
+
+const (
+	progressRedrawInterval time.Duration = 200 * time.Millisecond
+	progressBarWidth       int           = 30
+)
+
+type fileProgress struct {
+	transferred int64
+	total       int64
+}
+
+// Adds up the progress of every file in a transfer. On a terminal, it keeps a
+// single line with a progress bar up to date; otherwise it logs a line every
+// so often, so transfers that finish quickly don't print anything at all.
+type progressReporter struct {
+	lock sync.Mutex
+
+	w        io.Writer
+	terminal bool
+	interval time.Duration
+
+	files    map[string]*fileProgress
+	started  time.Time
+	printed  time.Time
+	finished bool
+}
+
+func newProgressReporter(w io.Writer, terminal bool, interval time.Duration) *progressReporter {
+	if terminal {
+		interval = progressRedrawInterval
+	}
+
+	now := time.Now()
+	return &progressReporter{
+		w:        w,
+		terminal: terminal,
+		interval: interval,
+		files:    map[string]*fileProgress{},
+		started:  now,
+		printed:  now,
+	}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Reports the progress of uploads and downloads made with the returned
+// context, until the returned function is called.
+func startProgress(cmd *cobra.Command, options *globalOptions) (context.Context, func()) {
+	if options.noProgress {
+		return cmd.Context(), func() {}
+	}
+
+	reporter := newProgressReporter(cmd.ErrOrStderr(), isTerminal(os.Stderr), options.progressInterval)
+	return blob.WithProgress(cmd.Context(), reporter.update), reporter.finish
+}
+
+func (p *progressReporter) update(path string, transferred int64, total int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, ok := p.files[path]
+	if !ok {
+		file = &fileProgress{}
+		p.files[path] = file
+	}
+	file.transferred = transferred
+	file.total = total
+
+	if p.finished {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(p.printed) < p.interval {
+		return
+	}
+
+	p.print(now)
+}
+
+// Prints the final totals, but only if anything was printed while the
+// transfer was running. Finishing more than once does nothing, so a command
+// can finish before printing its summary, and again when it returns.
+func (p *progressReporter) finish() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.finished || p.printed.Equal(p.started) {
+		p.finished = true
+		return
+	}
+	p.finished = true
+
+	p.print(time.Now())
+	if p.terminal {
+		fmt.Fprintln(p.w)
+	}
+}
+
+func (p *progressReporter) print(now time.Time) {
+	p.printed = now
+
+	transferred, total := p.totals()
+	elapsed := now.Sub(p.started)
+
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(transferred) / elapsed.Seconds()
+	}
+
+	amount := formatHumanSize(transferred)
+	percent := ""
+	eta := ""
+	if total >= 0 {
+		amount = fmt.Sprintf("%s of %s", amount, formatHumanSize(total))
+		if total > 0 {
+			percent = fmt.Sprintf("%d%%", transferred*100/total)
+		}
+		if rate > 0 {
+			remaining := time.Duration(float64(total-transferred) / rate * float64(time.Second))
+			eta = remaining.Round(time.Second).String()
+		}
+	}
+
+	speed := formatHumanSize(int64(rate)) + "/s"
+	files := len(p.files)
+
+	if p.terminal {
+		line := fmt.Sprintf("%s %4s %s in %d files, %s", progressBar(transferred, total), percent, amount, files, speed)
+		if eta != "" {
+			line += ", ETA " + eta
+		}
+
+		// Clear anything left over from a longer line.
+		fmt.Fprintf(p.w, "\r%s\033[K", line)
+		return
+	}
+
+	line := fmt.Sprintf("Progress: %s in %d files, %s", amount, files, speed)
+	if percent != "" {
+		line += " (" + percent + ")"
+	}
+	if eta != "" {
+		line += ", " + eta + " remaining"
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+// The total is only known once every file being transferred has reported
+// its size, and only covers the files that have started so far.
+func (p *progressReporter) totals() (int64, int64) {
+	transferred := int64(0)
+	total := int64(0)
+	for _, file := range p.files {
+		transferred += file.transferred
+		if file.total < 0 || total < 0 {
+			total = -1
+		} else {
+			total += file.total
+		}
+	}
+
+	return transferred, total
+}
+
+func progressBar(transferred int64, total int64) string {
+	if total <= 0 {
+		return "[" + strings.Repeat("-", progressBarWidth) + "]"
+	}
+
+	filled := int(transferred * int64(progressBarWidth) / total)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+}
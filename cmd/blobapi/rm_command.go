@@ -1,7 +1,11 @@
 package blobapi
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 )
 
 import (
@@ -12,25 +16,133 @@ import (
 	"github.com/Eagerod/blobstore-client/pkg/blob"
 )
 
-func newRmCommand(client blob.IBlobStoreClient) *cobra.Command {
+func newRmCommand(client blob.IBlobStoreClient, options *globalOptions) *cobra.Command {
+	var noGlob bool
+	var recursive bool
+	var yes bool
+	var dryRun bool
+
 	command := &cobra.Command{
-		Use:   "rm <BlobPath>",
+		Use:   "rm <BlobPath>...",
 		Short: "Remove from blobstore",
-		Long:  "Delete a file from the blobstore",
-		Args:  cobra.ExactArgs(1),
+		Long:  "Delete files from the blobstore",
+		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			rmArg, err := newBlobParsedArg(args[0])
-			if err != nil {
-				return err
+			rmArgs := []*url.URL{}
+			for _, arg := range args {
+				rmArg, err := newBlobParsedArg(arg)
+				if err != nil {
+					return err
+				}
+
+				if rmArg.Scheme != BlobStoreUrlScheme {
+					return errors.New("Cannot delete a local file")
+				}
+
+				if recursive {
+					if isBlobGlob(rmArg, noGlob) {
+						return errors.New("Can't delete files matching a glob recursively; use --no-glob to delete below a prefix containing glob characters")
+					}
+
+					rmArgs = append(rmArgs, rmArg)
+					continue
+				}
+
+				expanded, err := expandBlobArg(cmd.Context(), client, rmArg, noGlob)
+				if err != nil {
+					return err
+				}
+				rmArgs = append(rmArgs, expanded...)
+			}
+
+			if recursive {
+				return removeRecursive(cmd, client, options, rmArgs, yes, dryRun)
 			}
 
-			if rmArg.Scheme != BlobStoreUrlScheme {
-				return errors.New("Cannot delete a local file")
+			if dryRun {
+				for _, rmArg := range rmArgs {
+					fmt.Fprintf(cmd.OutOrStdout(), "Would delete %s\n", strings.TrimLeft(rmArg.Path, "/"))
+				}
+				return nil
 			}
 
-			return client.DeleteFile(rmArg)
+			for _, rmArg := range rmArgs {
+				if err := client.DeleteFileContext(cmd.Context(), rmArg); err != nil {
+					return err
+				}
+			}
+
+			return nil
 		},
 	}
 
+	addNoGlobFlag(command, &noGlob)
+	command.Flags().BoolVarP(&recursive, "recursive", "r", false, "Delete everything below each prefix")
+	command.Flags().BoolVarP(&yes, "yes", "y", false, "Delete recursively without asking for confirmation")
+	command.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print what would be deleted without deleting anything")
+
 	return command
-}
\ No newline at end of file
+}
+
+// Lists everything below the prefixes, and deletes it all once the user has
+// agreed to it.
+func removeRecursive(cmd *cobra.Command, client blob.IBlobStoreClient, options *globalOptions, prefixes []*url.URL, yes bool, dryRun bool) error {
+	stats := []blob.BlobFileStat{}
+	for _, prefix := range prefixes {
+		listed, err := client.ListPrefixStatContext(cmd.Context(), prefix.Path, true)
+		if err != nil {
+			return err
+		}
+
+		found := 0
+		for _, stat := range listed {
+			if !isListedDirectory(stat) {
+				stats = append(stats, stat)
+				found++
+			}
+		}
+
+		if found == 0 {
+			return fmt.Errorf("No files found below %s: %w", prefix.Path, blob.ErrNotFound)
+		}
+	}
+
+	totalBytes := 0
+	files := make([]*url.URL, 0, len(stats))
+	for _, stat := range stats {
+		totalBytes += stat.SizeBytes
+		files = append(files, blobKeyUrl(listedPath(stat)))
+	}
+
+	description := fmt.Sprintf("%d files, %d bytes", len(stats), totalBytes)
+
+	if dryRun {
+		if !options.outputFormat.isText() {
+			return options.outputFormat.writeRecords(cmd.OutOrStdout(), fileStatCsvHeader, fileStatRecords(stats))
+		}
+
+		for _, stat := range stats {
+			fmt.Fprintf(cmd.OutOrStdout(), "Would delete %s\n", listedPath(stat))
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Would delete %s\n", description)
+		return nil
+	}
+
+	if !yes {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Delete %s? [y/N] ", description)
+
+		answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if err != nil {
+			fmt.Fprintln(cmd.ErrOrStderr())
+		}
+
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			cmd.SilenceUsage = true
+			return errors.New("Not deleting anything")
+		}
+	}
+
+	summary, err := client.DeleteFilesContext(cmd.Context(), files)
+	return reportTransferSummary(cmd, options.outputFormat, "Deleted", summary, err)
+}
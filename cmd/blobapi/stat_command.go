@@ -0,0 +1,115 @@
+package blobapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+func newStatCommand(client blob.IBlobStoreClient, options *globalOptions) *cobra.Command {
+	var quiet bool
+	var noGlob bool
+
+	command := &cobra.Command{
+		Use:   "stat <BlobPath>...",
+		Short: "Show details of files on blobstore",
+		Long:  "Show the size, type and modification time of files in the blobstore, exiting with an error if any of them don't exist",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats := make([]blob.BlobFileStat, 0, len(args))
+			missing := 0
+
+			for _, arg := range args {
+				statArg, err := newBlobParsedArg(arg)
+				if err != nil {
+					return err
+				}
+
+				if statArg.Scheme != BlobStoreUrlScheme {
+					return errors.New("Must start remote stat path with blob:/")
+				}
+
+				statArgs, err := expandBlobArg(cmd.Context(), client, statArg, noGlob)
+				if err != nil {
+					return err
+				}
+
+				for _, statArg := range statArgs {
+					stat, err := client.StatFileContext(cmd.Context(), statArg)
+					if err != nil {
+						return err
+					}
+
+					if !stat.Exists {
+						missing++
+					}
+					stats = append(stats, *stat)
+				}
+			}
+
+			// Nothing about how the command was run is wrong, so the usage
+			// would only get in the way of the result.
+			cmd.SilenceUsage = true
+
+			if quiet {
+				cmd.SilenceErrors = true
+			} else if !options.outputFormat.isText() {
+				if err := options.outputFormat.writeRecords(cmd.OutOrStdout(), fileStatCsvHeader, fileStatRecords(stats)); err != nil {
+					return err
+				}
+			} else {
+				for i := range stats {
+					if i != 0 {
+						fmt.Fprintln(cmd.OutOrStdout())
+					}
+					printFileStat(cmd, &stats[i])
+				}
+			}
+
+			if missing != 0 {
+				return fmt.Errorf("%d of %d files do not exist: %w", missing, len(stats), blob.ErrNotFound)
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print nothing; only exit with an error if any file doesn't exist")
+	addNoGlobFlag(command, &noGlob)
+
+	return command
+}
+
+func printFileStat(cmd *cobra.Command, stat *blob.BlobFileStat) {
+	out := cmd.OutOrStdout()
+
+	fmt.Fprintf(out, "Path: %s\n", listedPath(*stat))
+	fmt.Fprintf(out, "Name: %s\n", stat.Name)
+	fmt.Fprintf(out, "Exists: %t\n", stat.Exists)
+	if !stat.Exists {
+		return
+	}
+
+	fmt.Fprintf(out, "Type: %s\n", formatListedValue(stat.MimeType))
+	fmt.Fprintf(out, "Size: %d\n", stat.SizeBytes)
+	fmt.Fprintf(out, "ETag: %s\n", formatListedValue(stat.ETag))
+	fmt.Fprintf(out, "Last-Modified: %s\n", formatListedTime(stat.LastModified))
+
+	keys := make([]string, 0, len(stat.Metadata))
+	for key := range stat.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(out, "Metadata %s: %s\n", key, stat.Metadata[key])
+	}
+}
@@ -0,0 +1,79 @@
+package blobapi
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+const DefaultSyncMaxDelete int = 100
+
+func newSyncCommand(client blob.IBlobStoreClient, options *globalOptions) *cobra.Command {
+	var deleteExtraneous bool
+	var maxDelete int
+	var dryRun bool
+	var include []string
+	var exclude []string
+
+	command := &cobra.Command{
+		Use:   "sync <LocalPath> <BlobPath> or <BlobPath> <LocalPath>",
+		Short: "Mirror files to or from blobstore",
+		Long:  "Copy only new or changed files between a local directory and a blobstore prefix",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			syncArg0, err := newBlobParsedArg(args[0])
+			if err != nil {
+				return err
+			}
+
+			syncArg1, err := newBlobParsedArg(args[1])
+			if err != nil {
+				return err
+			}
+
+			syncOptions := blob.SyncOptions{
+				Delete:    deleteExtraneous,
+				MaxDelete: maxDelete,
+				Include:   include,
+				Exclude:   exclude,
+			}
+
+			plan, err := client.PlanSyncContext(cmd.Context(), syncArg0, syncArg1, syncOptions)
+			if plan != nil && dryRun {
+				printSyncPlan(cmd, plan)
+			}
+
+			if err != nil || dryRun {
+				return err
+			}
+
+			ctx, finishProgress := startProgress(cmd, options)
+			summary, err := client.ApplySyncPlanContext(ctx, plan)
+			finishProgress()
+
+			return reportTransferSummary(cmd, options.outputFormat, "Synced", summary, err)
+		},
+	}
+
+	command.Flags().BoolVar(&deleteExtraneous, "delete", false, "Delete files from the destination that are not in the source")
+	command.Flags().IntVar(&maxDelete, "max-delete", DefaultSyncMaxDelete, "Refuse to sync if more than this many files would be deleted; negative for no limit")
+	command.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print what would be changed without changing anything")
+	command.Flags().StringArrayVar(&include, "include", []string{}, "Only sync files matching this glob pattern")
+	command.Flags().StringArrayVar(&exclude, "exclude", []string{}, "Do not sync files matching this glob pattern")
+
+	return command
+}
+
+func printSyncPlan(cmd *cobra.Command, plan *blob.SyncPlan) {
+	for _, action := range plan.Actions {
+		fmt.Fprintf(cmd.OutOrStdout(), "Would %s %s (%s)\n", action.Kind, action.RelativePath, action.Reason)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%d files to change, %d unchanged\n", len(plan.Actions), len(plan.Unchanged))
+}
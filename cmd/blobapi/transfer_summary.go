@@ -0,0 +1,43 @@
+package blobapi
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/spf13/cobra"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+)
+
+func printTransferSummary(cmd *cobra.Command, format *outputFormat, verb string, summary *blob.TransferSummary) error {
+	if !format.isText() {
+		return format.writeRecords(cmd.OutOrStdout(), transferCsvHeader, transferRecords(summary))
+	}
+
+	for _, failure := range summary.Failed {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Failed: %s\n", failure.Error())
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s %d files, skipped %d, failed %d\n", verb, len(summary.Transferred), len(summary.Skipped), len(summary.Failed))
+	return nil
+}
+
+// Prints whatever a bulk operation managed to do, even if it was stopped part
+// way through, and returns the reason it failed, if it did.
+func reportTransferSummary(cmd *cobra.Command, format *outputFormat, verb string, summary *blob.TransferSummary, err error) error {
+	if summary == nil {
+		return err
+	}
+
+	if printErr := printTransferSummary(cmd, format, verb, summary); printErr != nil {
+		return printErr
+	}
+
+	if err != nil {
+		return err
+	}
+	return summary.Err()
+}
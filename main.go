@@ -1,16 +1,41 @@
 package main
 
 import (
+	"errors"
 	"os"
 )
 
 import (
 	"github.com/Eagerod/blobstore-client/cmd/blobapi"
+	"github.com/Eagerod/blobstore-client/pkg/blob"
 )
 
-func main() {
-	if err := blobapi.Execute(); err != nil {
-		os.Exit(1)
+const (
+	ExitCodeSuccess      = 0
+	ExitCodeError        = 1
+	ExitCodeNotFound     = 3
+	ExitCodeUnauthorized = 4
+	ExitCodeForbidden    = 5
+	ExitCodeConflict     = 6
+)
+
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeSuccess
+	case errors.Is(err, blob.ErrNotFound):
+		return ExitCodeNotFound
+	case errors.Is(err, blob.ErrUnauthorized):
+		return ExitCodeUnauthorized
+	case errors.Is(err, blob.ErrForbidden):
+		return ExitCodeForbidden
+	case errors.Is(err, blob.ErrConflict):
+		return ExitCodeConflict
 	}
-	os.Exit(0)
+
+	return ExitCodeError
+}
+
+func main() {
+	os.Exit(exitCode(blobapi.Execute()))
 }
@@ -2,14 +2,17 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 import (
@@ -18,18 +21,20 @@ import (
 )
 
 import (
+	"github.com/Eagerod/blobstore-client/cmd/blobapi"
 	"github.com/Eagerod/blobstore-client/pkg/blob"
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+	"github.com/Eagerod/blobstore-client/pkg/config"
 	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
 )
 
-// NOTE: This package expects a fresh binary to have just been installed on the
-// machine. This should be updated to either use source, or use the binary in
-// the project's build directory if possible, but that may be more difficult
-// than needed.
+// NOTE: These tests build the binary from source, and run it against an
+// in-process fake blobstore, so they don't need network access.
 const testingAccessToken string = "ad4c3f2d4fb81f4118f837464b961eebda026d8c52a7cc967047cc3c2a3f6a43"
 const makefilePath string = "Makefile"
-const blobBinPath string = "./build/blob"
-const blobstoreBaseUrl string = "https://blob.aleemhaji.com"
+
+var blobBinPath string
+var blobstoreBaseUrl string
 
 var commands []string = make([]string, 0, 0)
 var blobCliHelpStrings map[string]string = make(map[string]string, 0)
@@ -69,9 +74,36 @@ func toURL(path string) *url.URL {
 }
 
 func TestMain(m *testing.M) {
-	if _, err := exec.LookPath(blobBinPath); err != nil {
-		panic("Failed to find executable to run system tests")
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	buildDir, err := ioutil.TempDir("", "blob")
+	if err != nil {
+		panic("Failed to create directory for test executable")
 	}
+	defer os.RemoveAll(buildDir)
+
+	blobBinPath = path.Join(buildDir, "blob")
+	if output, err := exec.Command("go", "build", "-o", blobBinPath, ".").CombinedOutput(); err != nil {
+		panic("Failed to build executable to run system tests: " + string(output))
+	}
+
+	server := blobtest.NewServer(blobtest.Config{WriteAcl: testingAccessToken})
+	defer server.Close()
+
+	blobstoreBaseUrl = server.URL
+	os.Setenv(blobapi.BlobStoreUrlEnvironmentVariable, server.URL)
+
+	// Keep any config on the machine running the tests from leaking in.
+	configHome, err := ioutil.TempDir("", "blob-config")
+	if err != nil {
+		panic("Failed to create directory for test config")
+	}
+	defer os.RemoveAll(configHome)
+
+	os.Setenv(config.XdgConfigHomeEnvironmentVariable, configHome)
+	os.Unsetenv(config.ProfileEnvironmentVariable)
 
 	commands = append(commands, "", "cp", "append", "rm")
 
@@ -105,7 +137,7 @@ func TestMain(m *testing.M) {
 	retCode := m.Run()
 
 	os.Remove("../Makefile2")
-	os.Exit(retCode)
+	return retCode
 }
 
 func TestCommandLineInterfaceUpload(t *testing.T) {
@@ -123,7 +155,7 @@ func TestCommandLineInterfaceUpload(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "", string(output))
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	contents, err := api.GetFileContents(toURL(remotePath))
 	assert.Nil(t, err)
 	defer api.DeleteFile(toURL(remotePath))
@@ -146,7 +178,7 @@ func TestCommandLineInterfaceUploadNoContentType(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "", string(output))
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	contents, err := api.GetFileContents(toURL(remotePath))
 	assert.Nil(t, err)
 	defer api.DeleteFile(toURL(remotePath))
@@ -158,7 +190,7 @@ func TestCommandLineInterfaceUploadAlreadyExists(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	err := api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 	assert.Nil(t, err)
 	defer api.DeleteFile(toURL(remotePath))
@@ -179,7 +211,7 @@ func TestCommandLineInterfaceUploadFails(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	err := api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 	assert.Nil(t, err)
 	defer api.DeleteFile(toURL(remotePath))
@@ -200,7 +232,7 @@ func TestCommandLineInterfaceDownload(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 
 	cmd := exec.Command(blobBinPath, "cp", remoteCliPath, "../Makefile2")
@@ -228,7 +260,7 @@ func TestCommandLineInterfaceDownloadToSdtout(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 
 	cmd := exec.Command(blobBinPath, "cp", remoteCliPath)
@@ -273,13 +305,14 @@ func TestCommandLineInterfaceDownloadFails(t *testing.T) {
 
 	expectedOutput := `Error: Blobstore Download Failed (404): {"code":"NotFound","message":"File not found"}` + "\n" + blobCliHelpStrings["cp"] + "\n"
 	assert.Equal(t, expectedOutput, string(output))
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode())
 }
 
 func TestCommandLineInterfaceAppend(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 
 	cmd := exec.Command(blobBinPath, "append", remoteCliPath, "--string", "something extra")
@@ -317,7 +350,7 @@ func TestCommandLineInterfaceAppendFails(t *testing.T) {
 func TestCommandLineInterfaceList(t *testing.T) {
 	remotePath := getTestFilePath()
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 
 	cmd := exec.Command(blobBinPath, "ls", "blob:/clientlib")
@@ -345,7 +378,7 @@ func TestCommandLineInterfaceList(t *testing.T) {
 func TestCommandLineInterfaceListRecursive(t *testing.T) {
 	remotePath := getTestFilePath()
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 
 	cmd := exec.Command(blobBinPath, "ls", "blob:/clientlib", "-r")
@@ -370,11 +403,187 @@ func TestCommandLineInterfaceListRecursive(t *testing.T) {
 	assert.True(t, found, fmt.Sprintf("Did not find %s in blobstorage.", remotePath))
 }
 
+func TestCommandLineInterfaceListLong(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	smallPath := path.Join(remotePrefix, "small.txt")
+	largePath := path.Join(remotePrefix, "large.bin")
+	assert.Nil(t, api.UploadStream(smallPath, bufio.NewReader(strings.NewReader("small")), "text/plain"))
+	assert.Nil(t, api.UploadStream(largePath, bufio.NewReader(strings.NewReader(strings.Repeat("x", 3*1024+512))), "application/octet-stream"))
+	defer api.DeleteFile(smallPath)
+	defer api.DeleteFile(largePath)
+
+	cases := []struct {
+		Args     []string
+		Expected []string
+	}{
+		{[]string{"-l"}, []string{"3584  application/octet-stream", "   5  text/plain"}},
+		{[]string{"-lh"}, []string{"3.5K  application/octet-stream", "   5  text/plain"}},
+		{[]string{"-l", "--sort", "size", "--reverse"}, []string{"   5  text/plain", "3584  application/octet-stream"}},
+	}
+
+	for _, ti := range cases {
+		cmd := exec.Command(blobBinPath, append([]string{"ls", getTestFileCliPath(remotePrefix)}, ti.Args...)...)
+		cmd.Env = makeEnv(testingAccessToken)
+
+		output, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(output))
+
+		lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+		assert.Equal(t, len(ti.Expected), len(lines), ti.Args)
+		for i := range lines {
+			assert.True(t, strings.HasPrefix(lines[i], ti.Expected[i]), lines[i])
+		}
+	}
+
+	cmd := exec.Command(blobBinPath, "ls", getTestFileCliPath(remotePrefix), "--sort", "size")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, largePath+"\n"+smallPath+"\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "ls", "--sort", "colour")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Unknown sort order colour; must be one of name, size, or time\n"), string(output))
+}
+
+func TestCommandLineInterfaceOutputFormats(t *testing.T) {
+	remotePrefix := getTestFilePath()
+	remotePath := path.Join(remotePrefix, "file.txt")
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	assert.Nil(t, api.UploadStream(remotePath, bufio.NewReader(strings.NewReader("contents")), "text/plain"))
+	defer api.DeleteFile(remotePath)
+
+	stat, err := api.GetStat(remotePath)
+	assert.Nil(t, err)
+	lastModified := stat.LastModified.UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		Args     []string
+		Expected string
+	}{
+		{
+			[]string{"ls", getTestFileCliPath(remotePrefix), "--output", "ndjson"},
+			`{"Path":"` + remotePrefix + `/","Name":"file.txt","MimeType":"text/plain","SizeBytes":8,"Exists":true,"ETag":` + strconv.Quote(stat.ETag) + `,"LastModified":"` + lastModified + `"}` + "\n",
+		},
+		{
+			[]string{"ls", getTestFileCliPath(remotePrefix), "--output", "csv"},
+			"Path,Name,MimeType,SizeBytes,Exists,ETag,LastModified,Metadata\n" + remotePrefix + "/,file.txt,text/plain,8,true,\"" + strings.ReplaceAll(stat.ETag, "\"", "\"\"") + "\"," + lastModified + ",\n",
+		},
+		{
+			[]string{"ls", getTestFileCliPath(remotePrefix), "--output", "template={{.Name}} {{.SizeBytes}}"},
+			"file.txt 8\n",
+		},
+		{
+			[]string{"stat", getTestFileCliPath(remotePath), "--output", "template={{.Exists}} {{.MimeType}}"},
+			"true text/plain\n",
+		},
+		{
+			[]string{"ls", getTestFileCliPath(path.Join(remotePrefix, "missing")), "--output", "json"},
+			"[]\n",
+		},
+	}
+
+	for _, ti := range cases {
+		cmd := exec.Command(blobBinPath, ti.Args...)
+		cmd.Env = makeEnv(testingAccessToken)
+
+		output, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(output))
+		assert.Equal(t, ti.Expected, string(output), ti.Args)
+	}
+
+	cmd := exec.Command(blobBinPath, "stat", getTestFileCliPath(remotePath), "--output", "json")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	stats := []blob.BlobFileStat{}
+	assert.Nil(t, json.Unmarshal(output, &stats))
+	assert.Equal(t, []blob.BlobFileStat{*stat}, stats)
+}
+
+func TestCommandLineInterfaceStat(t *testing.T) {
+	remotePath := getTestFilePath()
+	missingPath := getTestFilePath()
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	assert.Nil(t, api.UploadStream(remotePath, bufio.NewReader(strings.NewReader("contents")), "text/plain"))
+	defer api.DeleteFile(remotePath)
+
+	stat, err := api.GetStat(remotePath)
+	assert.Nil(t, err)
+
+	expectedFound := "Path: " + remotePath + "\nName: " + path.Base(remotePath) + "\nExists: true\nType: text/plain\nSize: 8\nETag: " + stat.ETag + "\nLast-Modified: " + stat.LastModified.UTC().Format("2006-01-02 15:04:05") + "\n"
+	expectedMissing := "Path: " + missingPath + "\nName: " + path.Base(missingPath) + "\nExists: false\n"
+
+	cases := []struct {
+		Args     []string
+		Expected string
+		ExitCode int
+	}{
+		{[]string{getTestFileCliPath(remotePath)}, expectedFound, ExitCodeSuccess},
+		{[]string{getTestFileCliPath(remotePath), getTestFileCliPath(missingPath)}, expectedFound + "\n" + expectedMissing + "Error: 1 of 2 files do not exist: Blobstore object not found\n", ExitCodeNotFound},
+		{[]string{"-q", getTestFileCliPath(remotePath)}, "", ExitCodeSuccess},
+		{[]string{"-q", getTestFileCliPath(remotePath), getTestFileCliPath(missingPath)}, "", ExitCodeNotFound},
+	}
+
+	for _, ti := range cases {
+		cmd := exec.Command(blobBinPath, append([]string{"stat"}, ti.Args...)...)
+		cmd.Env = makeEnv(testingAccessToken)
+
+		output, _ := cmd.CombinedOutput()
+		assert.Equal(t, ti.Expected, string(output), ti.Args)
+		assert.Equal(t, ti.ExitCode, cmd.ProcessState.ExitCode(), ti.Args)
+	}
+}
+
+func TestCommandLineInterfaceOutputTransferSummary(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	assert.Nil(t, ioutil.WriteFile(path.Join(localDir, "a.txt"), []byte("a"), 0644))
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	defer api.DeleteFile(path.Join(remotePrefix, "a.txt"))
+
+	cmd := exec.Command(blobBinPath, "cp", "-r", localDir, getTestFileCliPath(remotePrefix), "--output", "ndjson")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, `{"Path":"`+path.Join(localDir, "a.txt")+`","Status":"transferred"}`+"\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "cp", "-r", localDir, getTestFileCliPath(remotePrefix), "--output", "csv")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Path,Status,Error\n"+path.Join(localDir, "a.txt")+",skipped,\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "ls", "--output", "yaml")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Unknown output format yaml; must be one of text, json, ndjson, csv, or template=<template>\n"), string(output))
+}
+
 func TestCommandLineInterfaceDelete(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 
 	cmd := exec.Command(blobBinPath, "rm", remoteCliPath)
@@ -398,7 +607,7 @@ func TestCommandLineInterfaceDeleteFails(t *testing.T) {
 	remotePath := getTestFilePath()
 	remoteCliPath := getTestFileCliPath(remotePath)
 
-	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{testingAccessToken, testingAccessToken})
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
 	err := api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
 	assert.Nil(t, err)
 	defer api.DeleteFile(toURL(remotePath))
@@ -413,9 +622,860 @@ func TestCommandLineInterfaceDeleteFails(t *testing.T) {
 
 	expectedOutput := "Error: Blobstore Delete Failed (403): \n" + blobCliHelpStrings["rm"] + "\n"
 	assert.Equal(t, expectedOutput, string(output))
+	assert.Equal(t, ExitCodeForbidden, cmd.ProcessState.ExitCode())
 
 	stat, err := api.StatFile(toURL(remotePath))
 	assert.Nil(t, err)
 
 	assert.Equal(t, stat.Exists, true)
 }
+
+func TestCommandLineInterfaceCopyBlobToBlob(t *testing.T) {
+	remotePath := getTestFilePath()
+	copyPath := getTestFilePath()
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	assert.Nil(t, api.UploadFile(toURL(remotePath), makefilePath, "text/x-makefile"))
+	defer api.DeleteFile(toURL(remotePath))
+	defer api.DeleteFile(toURL(copyPath))
+
+	cmd := exec.Command(blobBinPath, "cp", getTestFileCliPath(remotePath), getTestFileCliPath(copyPath))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "", string(output))
+
+	stat, err := api.StatFile(toURL(copyPath))
+	assert.Nil(t, err)
+	assert.True(t, stat.Exists)
+	assert.Equal(t, "text/x-makefile", stat.MimeType)
+	assert.Equal(t, len(*makefileBytes), stat.SizeBytes)
+
+	stat, err = api.StatFile(toURL(remotePath))
+	assert.Nil(t, err)
+	assert.True(t, stat.Exists)
+}
+
+func TestCommandLineInterfaceMove(t *testing.T) {
+	remotePath := getTestFilePath()
+	movedPath := getTestFilePath()
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	assert.Nil(t, api.UploadFile(toURL(remotePath), makefilePath, "text/plain"))
+	defer api.DeleteFile(toURL(movedPath))
+
+	cmd := exec.Command(blobBinPath, "mv", getTestFileCliPath(remotePath), getTestFileCliPath(movedPath))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "", string(output))
+
+	stat, err := api.StatFile(toURL(remotePath))
+	assert.Nil(t, err)
+	assert.False(t, stat.Exists)
+
+	stat, err = api.StatFile(toURL(movedPath))
+	assert.Nil(t, err)
+	assert.True(t, stat.Exists)
+	assert.Equal(t, len(*makefileBytes), stat.SizeBytes)
+}
+
+func TestCommandLineInterfaceMoveFailsKeepsSource(t *testing.T) {
+	remotePath := getTestFilePath()
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	localPath := path.Join(localDir, "Makefile")
+	assert.Nil(t, ioutil.WriteFile(localPath, *makefileBytes, 0644))
+
+	cmd := exec.Command(blobBinPath, "mv", localPath, getTestFileCliPath(remotePath))
+	cmd.Env = makeEnv("")
+
+	output, err := cmd.CombinedOutput()
+	assert.NotNil(t, err, string(output))
+	assert.Equal(t, ExitCodeForbidden, cmd.ProcessState.ExitCode())
+
+	_, err = os.Stat(localPath)
+	assert.Nil(t, err)
+}
+
+func TestCommandLineInterfaceMoveRecursive(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	sourceDir := path.Join(localDir, "source")
+	assert.Nil(t, os.MkdirAll(path.Join(sourceDir, "nested"), 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(sourceDir, "a.txt"), []byte("a"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(sourceDir, "nested", "b.txt"), []byte("bb"), 0644))
+
+	cmd := exec.Command(blobBinPath, "mv", "-r", sourceDir, getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Moved 2 files, skipped 0, failed 0\n", string(output))
+
+	_, err = os.Stat(sourceDir)
+	assert.True(t, os.IsNotExist(err))
+
+	movedPrefix := getTestFilePath()
+	cmd = exec.Command(blobBinPath, "mv", "-r", getTestFileCliPath(remotePrefix), getTestFileCliPath(movedPrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Moved 2 files, skipped 0, failed 0\n", string(output))
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	defer api.DeleteFile(toURL(path.Join(movedPrefix, "a.txt")))
+	defer api.DeleteFile(toURL(path.Join(movedPrefix, "nested", "b.txt")))
+
+	keys, err := api.ListPrefix(toURL(remotePrefix).Path, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, keys)
+
+	stat, err := api.StatFile(toURL(path.Join(movedPrefix, "nested", "b.txt")))
+	assert.Nil(t, err)
+	assert.True(t, stat.Exists)
+	assert.Equal(t, 2, stat.SizeBytes)
+}
+
+func TestCommandLineInterfaceGlobs(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	for _, name := range []string{"2026-09-01.gz", "2026-09-02.gz", "2026-10-01.gz", "nested/2026-09-03.gz", "literal*.gz"} {
+		assert.Nil(t, api.UploadStream(path.Join(remotePrefix, name), bufio.NewReader(strings.NewReader(name)), "application/gzip"))
+		defer api.DeleteFile(path.Join(remotePrefix, name))
+	}
+
+	pattern := getTestFileCliPath(path.Join(remotePrefix, "2026-09-*"))
+
+	cmd := exec.Command(blobBinPath, "ls", pattern)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, path.Join(remotePrefix, "2026-09-01.gz")+"\n"+path.Join(remotePrefix, "2026-09-02.gz")+"\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "ls", getTestFileCliPath(path.Join(remotePrefix, "**", "2026-09-0?.gz")), "--output", "template={{.Name}}")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "2026-09-01.gz\n2026-09-02.gz\n2026-09-03.gz\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "ls", "-r", getTestFileCliPath(path.Join(remotePrefix, "nest*")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, path.Join(remotePrefix, "nested", "2026-09-03.gz")+"\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "ls", getTestFileCliPath(path.Join(remotePrefix, "nest*")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode())
+
+	cmd = exec.Command(blobBinPath, "stat", "-q", pattern)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	cmd = exec.Command(blobBinPath, "cp", pattern, path.Join(localDir, "out"))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Copied 2 files, skipped 0, failed 0\n", string(output))
+
+	contents, err := ioutil.ReadFile(path.Join(localDir, "out", "2026-09-02.gz"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-09-02.gz", string(contents))
+
+	cmd = exec.Command(blobBinPath, "stat", "--no-glob", getTestFileCliPath(path.Join(remotePrefix, "literal*.gz")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.True(t, strings.HasPrefix(string(output), "Path: "+path.Join(remotePrefix, "literal*.gz")+"\n"), string(output))
+
+	cmd = exec.Command(blobBinPath, "rm", pattern)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "", string(output))
+
+	keys, err := api.ListPrefix(remotePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{path.Join(remotePrefix, "2026-10-01.gz"), path.Join(remotePrefix, "literal*.gz"), path.Join(remotePrefix, "nested", "2026-09-03.gz")}, keys)
+
+	cmd = exec.Command(blobBinPath, "rm", pattern)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode())
+	assert.True(t, strings.HasPrefix(string(output), "Error: No files match /"+path.Join(remotePrefix, "2026-09-*")+"\n"), string(output))
+}
+
+func TestCommandLineInterfaceDeleteRecursive(t *testing.T) {
+	remotePrefix := getTestFilePath()
+	names := []string{"a.txt", "b.txt", "nested/c.txt"}
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	for _, name := range names {
+		assert.Nil(t, api.UploadStream(path.Join(remotePrefix, name), bufio.NewReader(strings.NewReader("abcd")), "text/plain"))
+		defer api.DeleteFile(path.Join(remotePrefix, name))
+	}
+
+	cmd := exec.Command(blobBinPath, "rm", "-r", "--dry-run", getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	expected := ""
+	for _, name := range names {
+		expected += "Would delete " + path.Join(remotePrefix, name) + "\n"
+	}
+	expected += "Would delete 3 files, 12 bytes\n"
+	assert.Equal(t, expected, string(output))
+
+	cmd = exec.Command(blobBinPath, "rm", "-r", "--yes", getTestFileCliPath(path.Join(remotePrefix, "*")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Can't delete files matching a glob recursively; use --no-glob to delete below a prefix containing glob characters\n"), string(output))
+
+	cmd = exec.Command(blobBinPath, "rm", "-r", getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+	cmd.Stdin = strings.NewReader("n\n")
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, "Delete 3 files, 12 bytes? [y/N] Error: Not deleting anything\n", string(output))
+
+	keys, err := api.ListPrefix(remotePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(keys))
+
+	cmd = exec.Command(blobBinPath, "rm", "-r", getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+	cmd.Stdin = strings.NewReader("yes\n")
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Delete 3 files, 12 bytes? [y/N] Deleted 3 files, skipped 0, failed 0\n", string(output))
+
+	keys, err = api.ListPrefix(remotePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, keys)
+
+	cmd = exec.Command(blobBinPath, "rm", "-r", "--yes", getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode())
+	assert.True(t, strings.HasPrefix(string(output), "Error: No files found below /"+remotePrefix+": Blobstore object not found\n"), string(output))
+}
+
+func TestCommandLineInterfaceDeleteRecursiveFailures(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	assert.Nil(t, api.UploadStream(path.Join(remotePrefix, "a.txt"), bufio.NewReader(strings.NewReader("a")), "text/plain"))
+	defer api.DeleteFile(path.Join(remotePrefix, "a.txt"))
+
+	cmd := exec.Command(blobBinPath, "rm", "-r", "--yes", "--output", "ndjson", getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv("")
+
+	output, err := cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, `{"Path":"`+path.Join(remotePrefix, "a.txt")+`","Status":"failed","Error":"Blobstore Delete Failed (403): "}`+"\nError: Failed to transfer 1 of 1 files\n", strings.SplitN(string(output), "Usage:", 2)[0])
+}
+
+func TestCommandLineInterfaceParallel(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("%d.txt", i)
+		assert.Nil(t, ioutil.WriteFile(path.Join(localDir, name), []byte(name), 0644))
+		defer api.DeleteFile(path.Join(remotePrefix, name))
+	}
+
+	cmd := exec.Command(blobBinPath, "cp", "-r", "--parallel", "3", localDir, getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Uploaded 10 files, skipped 0, failed 0\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "rm", "-r", "--yes", "--fail-fast", "--parallel", "1", getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv("")
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeForbidden, cmd.ProcessState.ExitCode())
+	assert.True(t, strings.HasPrefix(string(output), "Failed: "+path.Join(remotePrefix, "0.txt")+": Blobstore Delete Failed (403): \nDeleted 0 files, skipped 0, failed 1\n"), string(output))
+
+	cmd = exec.Command(blobBinPath, "ls", "--parallel", "0")
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: --parallel must be at least 1\n"), string(output))
+}
+
+func TestCommandLineInterfaceGlobCopyKeepsPaths(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	for _, name := range []string{"x/a.txt", "y/a.txt"} {
+		assert.Nil(t, api.UploadStream(path.Join(remotePrefix, name), bufio.NewReader(strings.NewReader(name)), "text/plain"))
+		defer api.DeleteFile(path.Join(remotePrefix, name))
+	}
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	cmd := exec.Command(blobBinPath, "cp", getTestFileCliPath(path.Join(remotePrefix, "**", "a.txt")), localDir)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Copied 2 files, skipped 0, failed 0\n", string(output))
+
+	for _, name := range []string{"x/a.txt", "y/a.txt"} {
+		contents, err := ioutil.ReadFile(path.Join(localDir, name))
+		assert.Nil(t, err)
+		assert.Equal(t, name, string(contents))
+	}
+}
+
+func TestCommandLineInterfaceNoGlobLiteralKeys(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	for _, name := range []string{"a", "a?b", "a[b]", "100%"} {
+		assert.Nil(t, api.UploadStream(path.Join(remotePrefix, name), bufio.NewReader(strings.NewReader(name)), "text/plain"))
+		defer api.DeleteFile(path.Join(remotePrefix, name))
+	}
+
+	cmd := exec.Command(blobBinPath, "stat", "--no-glob", getTestFileCliPath(path.Join(remotePrefix, "a[b]")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.True(t, strings.Contains(string(output), "Name: a[b]\n"), string(output))
+
+	cmd = exec.Command(blobBinPath, "stat", getTestFileCliPath(path.Join(remotePrefix, "100%")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.True(t, strings.Contains(string(output), "Name: 100%\n"), string(output))
+
+	cmd = exec.Command(blobBinPath, "stat", getTestFileCliPath(path.Join(remotePrefix, "missing%")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode(), string(output))
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	cmd = exec.Command(blobBinPath, "cp", "--no-glob", getTestFileCliPath(path.Join(remotePrefix, "a?b")), path.Join(localDir, "a?b"))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	contents, err := ioutil.ReadFile(path.Join(localDir, "a?b"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a?b", string(contents))
+
+	cmd = exec.Command(blobBinPath, "rm", "--no-glob", getTestFileCliPath(path.Join(remotePrefix, "a?b")), getTestFileCliPath(path.Join(remotePrefix, "a[b]")))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "", string(output))
+
+	keys, err := api.ListPrefix(remotePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{path.Join(remotePrefix, "100%"), path.Join(remotePrefix, "a")}, keys)
+}
+
+func TestCommandLineInterfaceProgress(t *testing.T) {
+	remotePath := getTestFilePath()
+	remoteCliPath := getTestFileCliPath(remotePath)
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	defer api.DeleteFile(toURL(remotePath))
+
+	cmd := exec.Command(blobBinPath, "cp", "--progress-interval", "1ns", makefilePath, remoteCliPath)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "Progress: "), string(output))
+	}
+	assert.True(t, strings.Contains(lines[len(lines)-1], " in 1 files, "), string(output))
+	assert.True(t, strings.Contains(lines[len(lines)-1], "(100%)"), string(output))
+
+	cmd = exec.Command(blobBinPath, "cp", "--progress-interval", "1ns", "--no-progress", "--force", makefilePath, remoteCliPath)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "", string(output))
+}
+
+func withoutEnv(env []string, key string) []string {
+	rv := make([]string, 0, len(env))
+	for _, e := range env {
+		if !strings.HasPrefix(e, key+"=") {
+			rv = append(rv, e)
+		}
+	}
+
+	return rv
+}
+
+func withEnv(env []string, values ...string) []string {
+	rv := make([]string, 0, len(env)+len(values))
+	rv = append(rv, env...)
+	return append(rv, values...)
+}
+
+func TestCommandLineInterfaceConfig(t *testing.T) {
+	configHome, err := ioutil.TempDir("", "blob-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(configHome)
+
+	env := withEnv(makeEnv(testingAccessToken), config.XdgConfigHomeEnvironmentVariable+"="+configHome)
+
+	cmd := exec.Command(blobBinPath, "--profile", "staging", "config", "set", "endpoint", "https://blob.staging.example.com")
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	cmd = exec.Command(blobBinPath, "config", "set", "retries", "5")
+	cmd.Env = withEnv(env, config.ProfileEnvironmentVariable+"=staging")
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	cmd = exec.Command(blobBinPath, "--profile", "staging", "config", "get", "endpoint")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err)
+	assert.Equal(t, "https://blob.staging.example.com\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "--profile", "staging", "config", "list")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err)
+	assert.Equal(t, "endpoint = https://blob.staging.example.com\nretries = 5\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "config", "get", "endpoint")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: endpoint is not set in profile default\n"))
+
+	cmd = exec.Command(blobBinPath, "config", "set", "colour", "blue")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Unknown config key colour; must be one of endpoint, credentials, credentials_command, timeout, retries, or credentials.<prefix>\n"))
+}
+
+func TestCommandLineInterfaceEndpointPrecedence(t *testing.T) {
+	configHome, err := ioutil.TempDir("", "blob-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(configHome)
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	remotePath := getTestFilePath()
+	err = api.UploadFile(toURL(remotePath), makefilePath, "text/plain")
+	assert.Nil(t, err)
+	defer api.DeleteFile(toURL(remotePath))
+
+	env := withEnv(makeEnv(testingAccessToken), config.XdgConfigHomeEnvironmentVariable+"="+configHome)
+	envWithoutUrl := withoutEnv(env, blobapi.BlobStoreUrlEnvironmentVariable)
+	unreachable := "http://127.0.0.1:1"
+
+	cmd := exec.Command(blobBinPath, "--profile", "staging", "config", "set", "endpoint", blobstoreBaseUrl)
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	cmd = exec.Command(blobBinPath, "config", "set", "endpoint", unreachable)
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	cases := []struct {
+		name    string
+		args    []string
+		env     []string
+		success bool
+	}{
+		{"default profile", []string{}, envWithoutUrl, false},
+		{"profile flag", []string{"--profile", "staging"}, envWithoutUrl, true},
+		{"profile env", []string{}, withEnv(envWithoutUrl, config.ProfileEnvironmentVariable+"=staging"), true},
+		{"env beats profile", []string{"--profile", "staging"}, withEnv(envWithoutUrl, blobapi.BlobStoreUrlEnvironmentVariable+"="+unreachable), false},
+		{"flag beats env", []string{"--endpoint", blobstoreBaseUrl}, withEnv(envWithoutUrl, blobapi.BlobStoreUrlEnvironmentVariable+"="+unreachable), true},
+	}
+
+	for _, c := range cases {
+		args := append([]string{"ls", "blob:/clientlib/testing", "-r", "--retries", "0"}, c.args...)
+
+		cmd := exec.Command(blobBinPath, args...)
+		cmd.Env = c.env
+		output, err := cmd.CombinedOutput()
+		if c.success {
+			assert.Nil(t, err, c.name)
+			assert.Contains(t, string(output), remotePath, c.name)
+		} else {
+			assert.NotNil(t, err, c.name)
+		}
+	}
+
+	cmd = exec.Command(blobBinPath, "--profile", "production", "ls")
+	cmd.Env = envWithoutUrl
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Profile production not found in "))
+}
+
+func TestCommandLineInterfaceCredentialsFile(t *testing.T) {
+	configHome, err := ioutil.TempDir("", "blob-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(configHome)
+
+	credentialsPath := path.Join(configHome, "blob", credential_provider.CredentialsFileName)
+	assert.Nil(t, os.MkdirAll(path.Dir(credentialsPath), 0700))
+	assert.Nil(t, ioutil.WriteFile(credentialsPath, []byte("[staging]\nwrite_acl = "+testingAccessToken+"\n"), 0600))
+
+	env := withoutEnv(os.Environ(), credential_provider.DefaultBlobStoreReadAclEnvironmentVariable)
+	env = withoutEnv(env, credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable)
+	env = withEnv(env, config.XdgConfigHomeEnvironmentVariable+"="+configHome)
+
+	remotePath := getTestFilePath()
+	remoteCliPath := getTestFileCliPath(remotePath)
+
+	cmd := exec.Command(blobBinPath, "cp", makefilePath, remoteCliPath, "--retries", "0")
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Blobstore Upload Failed (403)"))
+
+	cmd = exec.Command(blobBinPath, "--profile", "staging", "cp", makefilePath, remoteCliPath)
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	defer api.DeleteFile(toURL(remotePath))
+
+	assert.Nil(t, os.Chmod(credentialsPath, 0644))
+
+	cmd = exec.Command(blobBinPath, "--profile", "staging", "cp", makefilePath, remoteCliPath, "--force")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Credentials file "+credentialsPath+" is accessible by other users"), string(output))
+}
+
+func TestCommandLineInterfaceExecCredentials(t *testing.T) {
+	configHome, err := ioutil.TempDir("", "blob-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(configHome)
+
+	script := path.Join(configHome, "credentials.sh")
+	contents := "#!/bin/sh\necho '{\"version\": 1, \"write_acl\": \"" + testingAccessToken + "\"}'\n"
+	assert.Nil(t, ioutil.WriteFile(script, []byte(contents), 0700))
+
+	env := withoutEnv(os.Environ(), credential_provider.DefaultBlobStoreReadAclEnvironmentVariable)
+	env = withoutEnv(env, credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable)
+	env = withEnv(env, config.XdgConfigHomeEnvironmentVariable+"="+configHome)
+
+	for _, kv := range [][]string{{"credentials", "exec"}, {"credentials_command", script}} {
+		cmd := exec.Command(blobBinPath, "config", "set", kv[0], kv[1])
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(output))
+	}
+
+	remotePath := getTestFilePath()
+	remoteCliPath := getTestFileCliPath(remotePath)
+
+	cmd := exec.Command(blobBinPath, "cp", makefilePath, remoteCliPath)
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	defer api.DeleteFile(toURL(remotePath))
+
+	contents, err = api.GetFileContents(toURL(remotePath))
+	assert.Nil(t, err)
+	assert.Equal(t, string(*makefileBytes), contents)
+}
+
+func TestCommandLineInterfaceAuthExplain(t *testing.T) {
+	env := withoutEnv(os.Environ(), credential_provider.DefaultBlobStoreReadAclEnvironmentVariable)
+	env = withoutEnv(env, credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable)
+	env = withEnv(env, credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable+"="+testingAccessToken)
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	remotePath := getTestFilePath()
+	assert.Nil(t, api.UploadFile(toURL(remotePath), makefilePath, "text/plain"))
+	defer api.DeleteFile(toURL(remotePath))
+
+	cmd := exec.Command(blobBinPath, "auth", "explain", getTestFileCliPath(remotePath), "--probe")
+	cmd.Env = env
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	expectedOutput := "Endpoint: " + blobstoreBaseUrl + "/\n" +
+		"Profile: default\n" +
+		"Request: HEAD /" + remotePath + "\n" +
+		"X-Blobstore-Read-Acl: <empty> (from direct credentials)\n" +
+		"Probe: server accepted read credentials\n"
+	assert.Equal(t, expectedOutput, string(output))
+
+	cmd = exec.Command(blobBinPath, "auth", "explain", "blob:/clientlib", "--probe", "-X", "GET")
+	cmd.Env = env
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Contains(t, string(output), "Probe: /clientlib does not exist, so the read credentials could not be checked\n")
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode())
+
+	// Write credentials are only checked when asked for, by creating a
+	// temporary file and removing it again.
+	writePrefix := getTestFilePath()
+	cmd = exec.Command(blobBinPath, "auth", "explain", getTestFileCliPath(writePrefix), "--probe-write", "-X", "POST")
+	cmd.Env = env
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.True(t, strings.HasSuffix(string(output), "Probe: server accepted write credentials\n"), string(output))
+
+	keys, err := api.ListPrefix(writePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, keys)
+
+	cmd = exec.Command(blobBinPath, "auth", "explain", "blob:/clientlib", "--probe-write", "-X", "POST", "--retries", "0")
+	cmd.Env = withEnv(withoutEnv(env, credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable), credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable+"=wrong")
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Contains(t, string(output), "Probe: server rejected write credentials\n")
+	assert.Equal(t, ExitCodeForbidden, cmd.ProcessState.ExitCode())
+
+	cmd = exec.Command(blobBinPath, "auth", "explain", "blob:/clientlib", "-X", "post")
+	cmd.Env = env
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	expectedOutput = "Endpoint: " + blobstoreBaseUrl + "/\n" +
+		"Profile: default\n" +
+		"Request: POST /clientlib\n" +
+		"X-Blobstore-Write-Acl: ****6a43 (from environment variables BLOBSTORE_READ_ACL and BLOBSTORE_WRITE_ACL)\n"
+	assert.Equal(t, expectedOutput, string(output))
+	assert.NotContains(t, string(output), testingAccessToken)
+
+	cmd = exec.Command(blobBinPath, "auth", "explain", "-X", "POST", "--profile", "missing")
+	cmd.Env = withoutEnv(env, blobapi.BlobStoreUrlEnvironmentVariable)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Profile missing not found in "))
+}
+
+func TestCommandLineInterfacePrefixCredentials(t *testing.T) {
+	configHome, err := ioutil.TempDir("", "blob-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(configHome)
+
+	credentialsPath := path.Join(configHome, "blob", credential_provider.CredentialsFileName)
+	assert.Nil(t, os.MkdirAll(path.Dir(credentialsPath), 0700))
+	credentials := "[default]\nwrite_acl = wrong\n\n[team-a]\nwrite_acl = " + testingAccessToken + "\n"
+	assert.Nil(t, ioutil.WriteFile(credentialsPath, []byte(credentials), 0600))
+
+	env := withoutEnv(os.Environ(), credential_provider.DefaultBlobStoreReadAclEnvironmentVariable)
+	env = withoutEnv(env, credential_provider.DefaultBlobStoreWriteAclEnvironmentVariable)
+	env = withEnv(env, config.XdgConfigHomeEnvironmentVariable+"="+configHome)
+
+	cmd := exec.Command(blobBinPath, "config", "set", "credentials.clientlib/team-a/", "team-a")
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+
+	teamPath := path.Join("clientlib", "team-a", uuid.New().String())
+	cmd = exec.Command(blobBinPath, "cp", makefilePath, getTestFileCliPath(teamPath))
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	defer api.DeleteFile(toURL(teamPath))
+
+	cmd = exec.Command(blobBinPath, "cp", makefilePath, getTestFileCliPath(getTestFilePath()), "--retries", "0")
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(string(output), "Error: Blobstore Upload Failed (403)"))
+
+	cmd = exec.Command(blobBinPath, "auth", "explain", "-X", "POST", getTestFileCliPath(teamPath))
+	cmd.Env = env
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Contains(t, string(output), "X-Blobstore-Write-Acl: ****6a43 (from credentials file "+credentialsPath+" (profile team-a), for prefix clientlib/team-a/)\n")
+}
+
+func TestCommandLineInterfaceSync(t *testing.T) {
+	remotePrefix := getTestFilePath()
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	assert.Nil(t, os.MkdirAll(path.Join(localDir, "nested"), 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(localDir, "a.txt"), []byte("a"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(localDir, "nested", "b.txt"), []byte("b"), 0644))
+
+	api := blob.NewBlobStoreApiClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	for _, name := range []string{"a.txt", "extra1.txt", "extra2.txt"} {
+		assert.Nil(t, api.UploadStream(path.Join(remotePrefix, name), bufio.NewReader(strings.NewReader("x")), "text/plain"))
+	}
+	for _, name := range []string{"a.txt", "nested/b.txt", "extra1.txt", "extra2.txt"} {
+		defer api.DeleteFile(path.Join(remotePrefix, name))
+	}
+
+	cmd := exec.Command(blobBinPath, "sync", "--dry-run", localDir, getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Would upload a.txt (checksum differs)\nWould upload nested/b.txt (new)\n2 files to change, 0 unchanged\n", string(output))
+
+	cmd = exec.Command(blobBinPath, "sync", "--delete", "--max-delete", "1", localDir, getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeError, cmd.ProcessState.ExitCode())
+	assert.True(t, strings.HasPrefix(string(output), "Error: Refusing to delete 2 files, which is more than the limit of 1\n"), string(output))
+
+	// Nothing is touched when the sync is refused.
+	keys, err := api.ListPrefix(remotePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(keys))
+
+	cmd = exec.Command(blobBinPath, "sync", "--delete", localDir, getTestFileCliPath(remotePrefix))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "Synced 4 files, skipped 0, failed 0\n", string(output))
+
+	keys, err = api.ListPrefix(remotePrefix, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{path.Join(remotePrefix, "a.txt"), path.Join(remotePrefix, "nested", "b.txt")}, keys)
+
+	cmd = exec.Command(blobBinPath, "sync", "--dry-run", getTestFileCliPath(remotePrefix), localDir)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "0 files to change, 2 unchanged\n", string(output))
+}
+
+func TestCommandLineInterfaceCopyContinue(t *testing.T) {
+	remotePath := getTestFilePath()
+
+	api := blob.NewBlobStoreClient(blobstoreBaseUrl, &credential_provider.DirectCredentialProvider{ReadAcl: testingAccessToken, WriteAcl: testingAccessToken})
+	assert.Nil(t, api.UploadFile(toURL(remotePath), makefilePath, "text/plain"))
+	defer api.DeleteFile(toURL(remotePath))
+
+	makefileBytes, err := ioutil.ReadFile(makefilePath)
+	assert.Nil(t, err)
+
+	stat, err := api.StatFile(toURL(remotePath))
+	assert.Nil(t, err)
+
+	localDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(localDir)
+
+	// Leave behind what an interrupted download would have, with its first
+	// bytes changed so that it's clear they were kept rather than fetched.
+	dest := path.Join(localDir, "Makefile")
+	assert.Nil(t, ioutil.WriteFile(dest+blob.PartialDownloadSuffix, []byte("XXXX"), 0644))
+
+	state, err := json.Marshal(map[string]interface{}{
+		"Path":      "/" + remotePath,
+		"SizeBytes": stat.SizeBytes,
+		"Validator": stat.ETag,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(dest+blob.PartialDownloadStateSuffix, state, 0644))
+
+	cmd := exec.Command(blobBinPath, "cp", "--continue", getTestFileCliPath(remotePath), dest)
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+	assert.Equal(t, "", string(output))
+
+	contents, err := ioutil.ReadFile(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, "XXXX"+string(makefileBytes[4:]), string(contents))
+
+	_, err = os.Stat(dest + blob.PartialDownloadSuffix)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(dest + blob.PartialDownloadStateSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	cmd = exec.Command(blobBinPath, "cp", "--continue", getTestFileCliPath(getTestFilePath()), path.Join(localDir, "missing"))
+	cmd.Env = makeEnv(testingAccessToken)
+
+	output, err = cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	assert.Equal(t, ExitCodeNotFound, cmd.ProcessState.ExitCode(), string(output))
+}
@@ -2,11 +2,13 @@ package blob
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -18,17 +20,24 @@ import (
 	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
 )
 
+// The JSON field names are part of the CLI's machine-readable output, so
+// they must not change.
 type BlobFileStat struct {
-	Path      string
-	Name      string
-	MimeType  string
-	SizeBytes int
-	Exists    bool
+	Path         string    `json:"Path"`
+	Name         string    `json:"Name"`
+	MimeType     string    `json:"MimeType"`
+	SizeBytes    int       `json:"SizeBytes"`
+	Exists       bool      `json:"Exists"`
+	ETag         string    `json:"ETag"`
+	LastModified time.Time `json:"LastModified"`
+
+	// Any metadata headers the server returned, without their prefix.
+	Metadata map[string]string `json:"Metadata,omitempty"`
 }
 
 type BlobFile struct {
 	Info 	 BlobFileStat
-	Contents io.Reader
+	Contents io.ReadCloser
 }
 
 type IHttpClient interface {
@@ -41,13 +50,24 @@ type IHttpClient interface {
 
 type IBlobStoreApiClient interface {
 	UploadStream(path string, stream *bufio.Reader, contentType string) error
+	UploadStreamContext(ctx context.Context, path string, stream *bufio.Reader, contentType string) error
+	UploadSeekableStream(path string, stream io.ReadSeeker, contentType string) error
+	UploadSeekableStreamContext(ctx context.Context, path string, stream io.ReadSeeker, contentType string) error
 
 	GetStat(path string) (*BlobFileStat, error)
+	GetStatContext(ctx context.Context, path string) (*BlobFileStat, error)
 	GetFile(path string) (*BlobFile, error)
+	GetFileContext(ctx context.Context, path string) (*BlobFile, error)
+	GetFileRange(path string, offset, length int64) (*BlobFile, error)
+	GetFileRangeContext(ctx context.Context, path string, offset, length int64) (*BlobFile, error)
 
 	ListPrefix(prefix string, recursive bool) ([]string, error)
+	ListPrefixContext(ctx context.Context, prefix string, recursive bool) ([]string, error)
+	ListPrefixStat(prefix string, recursive bool) ([]BlobFileStat, error)
+	ListPrefixStatContext(ctx context.Context, prefix string, recursive bool) ([]BlobFileStat, error)
 
 	DeleteFile(path string) error
+	DeleteFileContext(ctx context.Context, path string) error
 }
 
 type BlobStoreApiClient struct {
@@ -55,26 +75,102 @@ type BlobStoreApiClient struct {
 	credentialProvider credential_provider.ICredentialProvider
 
 	http IHttpClient
+
+	retryPolicy RetryPolicy
+	logger      *log.Logger
 }
 
-func NewBlobStoreApiClient(baseUrl string, credentialProvider credential_provider.ICredentialProvider) *BlobStoreApiClient {
-	// Make sure that the base url looks like a path, so that url resolution
-	// always uses the full base url as the prefix.
+// Make sure that the base url looks like a path, so that url resolution
+// always uses the full base url as the prefix.
+func normalizeBaseUrl(baseUrl string) string {
 	if !strings.HasSuffix(baseUrl, "/") {
-		baseUrl = baseUrl + "/"
+		return baseUrl + "/"
 	}
 
+	return baseUrl
+}
+
+func NewBlobStoreApiClient(baseUrl string, credentialProvider credential_provider.ICredentialProvider) *BlobStoreApiClient {
 	return &BlobStoreApiClient{
-		baseUrl,
+		normalizeBaseUrl(baseUrl),
 		credentialProvider,
 		&http.Client{Timeout: time.Second * 30},
+		NewRetryPolicy(0),
+		nil,
+	}
+}
+
+func (b *BlobStoreApiClient) BaseUrl() string {
+	return b.baseUrl
+}
+
+func (b *BlobStoreApiClient) SetBaseUrl(baseUrl string) {
+	b.baseUrl = normalizeBaseUrl(baseUrl)
+}
+
+func (b *BlobStoreApiClient) SetCredentialProvider(credentialProvider credential_provider.ICredentialProvider) {
+	b.credentialProvider = credentialProvider
+}
+
+// Only applies when the client is using the default http client.
+func (b *BlobStoreApiClient) SetTimeout(timeout time.Duration) {
+	if client, ok := b.http.(*http.Client); ok {
+		client.Timeout = timeout
+	}
+}
+
+// Keeps enough idle connections to the blobstore around for this many
+// concurrent requests to reuse them, rather than opening new ones. Only
+// applies when the client is using the default http client.
+func (b *BlobStoreApiClient) SetMaxIdleConnections(count int) {
+	client, ok := b.http.(*http.Client)
+	if !ok {
+		return
+	}
+
+	// Leave the default transport alone, since others may be sharing it.
+	if client.Transport == nil {
+		client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.MaxIdleConnsPerHost = count
+	}
+}
+
+func (b *BlobStoreApiClient) SetRetryPolicy(retryPolicy RetryPolicy) {
+	b.retryPolicy = retryPolicy
+}
+
+func (b *BlobStoreApiClient) SetLogger(logger *log.Logger) {
+	b.logger = logger
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Pulls the complete size of the file out of a header like "bytes 0-9/1234".
+func parseContentRangeSize(contentRange string) (int, bool) {
+	slash := strings.LastIndex(contentRange, "/")
+	if slash == -1 {
+		return 0, false
 	}
+
+	size, err := strconv.Atoi(contentRange[slash+1:])
+	if err != nil {
+		return 0, false
+	}
+
+	return size, true
 }
 
 func NewBlobFileStatFromResponse(basePathComponent string, response *http.Response) BlobFileStat {
 	val := BlobFileStat{
 		MimeType: response.Header.Get("Content-Type"),
 		Exists:   true,
+		ETag:     response.Header.Get("ETag"),
 	}
 
 	// Have to remove components based on the API base URL.
@@ -95,28 +191,28 @@ func NewBlobFileStatFromResponse(basePathComponent string, response *http.Respon
 		val.SizeBytes = size
 	}
 
-	if response.StatusCode == 404 {
-		val.Exists = false
+	lastModified, err := http.ParseTime(response.Header.Get("Last-Modified"))
+	if err == nil {
+		val.LastModified = lastModified
 	}
 
-	return val
-}
-
-func NewBlobStoreHttpError(operation string, response *http.Response) error {
-	if response.Body == nil {
-		return errors.New(fmt.Sprintf("Blobstore %s Failed (%d)", operation, response.StatusCode))
+	for header := range response.Header {
+		if strings.HasPrefix(header, HttpResponseMetadataHeaderPrefix) && len(header) > len(HttpResponseMetadataHeaderPrefix) {
+			if val.Metadata == nil {
+				val.Metadata = map[string]string{}
+			}
+			val.Metadata[header[len(HttpResponseMetadataHeaderPrefix):]] = response.Header.Get(header)
+		}
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return err
+	if response.StatusCode == 404 {
+		val.Exists = false
 	}
 
-	return errors.New(fmt.Sprintf("Blobstore %s Failed (%d): %s", operation, response.StatusCode, string(body)))
+	return val
 }
 
-// This should be adapted to return an error, rather than panicing.
-func (b *BlobStoreApiClient) route(path string) string {
+func (b *BlobStoreApiClient) route(path string) (string, error) {
 	// Always remove a / prefix on `path`, since it will resolve itself down to
 	// the host, rather than whatever additional pathing we want to add to the
 	// BlobStore default URL.
@@ -124,31 +220,132 @@ func (b *BlobStoreApiClient) route(path string) string {
 		path = path[1:]
 	}
 
-	pathUrlComponent, err := url.Parse(path)
+	baseUrlComponent, err := url.Parse(b.baseUrl)
+	if err != nil {
+		return "", err
+	}
+
+	// Keys are used as they are, rather than parsed, so that a ?, # or % in
+	// one is escaped instead of starting a query or fragment, or failing.
+	return baseUrlComponent.ResolveReference(&url.URL{Path: path}).String(), nil
+}
+
+func (b *BlobStoreApiClient) newAuthorizedRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	routed, err := b.route(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	baseUrlComponent, err := url.Parse(b.baseUrl)
+	request, err := http.NewRequestWithContext(ctx, method, routed, body)
 	if err != nil {
-		panic(err)
+		return request, err
 	}
 
-	return baseUrlComponent.ResolveReference(pathUrlComponent).String()
+	err = b.credentialProvider.AuthorizeRequest(request)
+	return request, err
 }
 
-func (b *BlobStoreApiClient) newAuthorizedRequest(method, path string, body io.Reader) (*http.Request, error) {
-	request, err := http.NewRequest(method, b.route(path), body)
+// The query is added after the prefix is routed, so that a ? in the prefix
+// stays part of it.
+func (b *BlobStoreApiClient) newAuthorizedListRequest(ctx context.Context, prefix string, query string) (*http.Request, error) {
+	routed, err := b.route("_dir/" + prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", routed, nil)
 	if err != nil {
 		return request, err
 	}
 
+	request.URL.RawQuery = query
 	err = b.credentialProvider.AuthorizeRequest(request)
 	return request, err
 }
 
+// Builds the request that would be sent, and reports where each of its
+// credential headers came from, without sending it.
+func (b *BlobStoreApiClient) ExplainAuthorization(ctx context.Context, method, path string) ([]credential_provider.CredentialSource, error) {
+	routed, err := b.route(path)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, routed, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return credential_provider.ExplainAuthorization(b.credentialProvider, request)
+}
+
+func (b *BlobStoreApiClient) logf(format string, v ...interface{}) {
+	if b.logger != nil {
+		b.logger.Printf(format, v...)
+	}
+}
+
+// Reads whatever is left of a response that isn't handed back to the caller,
+// so that its connection can go back to the pool.
+func closeResponseBody(response *http.Response) {
+	if response.Body == nil {
+		return
+	}
+
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+}
+
+// Sends the request produced by newRequest, sending a fresh one after a
+// backoff if the failure looks transient. Only requests that are safe to
+// repeat are ever retried.
+func (b *BlobStoreApiClient) doWithRetries(ctx context.Context, idempotent bool, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	maxAttempts := b.retryPolicy.MaxAttempts
+	if !idempotent || maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := b.http.Do(request)
+		if attempt >= maxAttempts || !shouldRetry(ctx, response, err) {
+			return response, err
+		}
+
+		delay := b.retryPolicy.backoff(attempt)
+		if serverDelay, ok := retryAfter(response); ok {
+			delay = serverDelay
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = response.Status
+			if response.Body != nil {
+				io.Copy(ioutil.Discard, response.Body)
+				response.Body.Close()
+			}
+		}
+
+		b.logf("Blobstore %s %s failed (%s); retrying in %s (attempt %d of %d)", request.Method, request.URL.Path, reason, delay.Round(time.Millisecond), attempt+1, maxAttempts)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
 func (b *BlobStoreApiClient) UploadStream(path string, stream *bufio.Reader, contentType string) error {
-	request, err := b.newAuthorizedRequest("POST", path, stream)
+	return b.UploadStreamContext(context.Background(), path, stream, contentType)
+}
+
+func (b *BlobStoreApiClient) UploadStreamContext(ctx context.Context, path string, stream *bufio.Reader, contentType string) error {
+	request, err := b.newAuthorizedRequest(ctx, "POST", path, newProgressReader(ctx, path, stream, -1))
 	if err != nil {
 		return err
 	}
@@ -164,28 +361,106 @@ func (b *BlobStoreApiClient) UploadStream(path string, stream *bufio.Reader, con
 
 	request.Header.Add("Content-Type", contentType)
 
-	response, err := b.http.Do(request)
+	// The stream can't be rewound, so this request is never retried.
+	response, err := b.doWithRetries(ctx, false, func() (*http.Request, error) {
+		return request, nil
+	})
 	if err != nil {
 		return err
 	}
+	defer closeResponseBody(response)
 
 	if response.StatusCode != 200 {
-		return NewBlobStoreHttpError("Upload", response)
+		return NewBlobStoreHttpError("Upload", path, response)
 	}
 
 	return nil
 }
 
-func (b *BlobStoreApiClient) GetStat(path string) (*BlobFileStat, error) {
-	request, err := b.newAuthorizedRequest("HEAD", path, nil)
+func (b *BlobStoreApiClient) UploadSeekableStream(path string, stream io.ReadSeeker, contentType string) error {
+	return b.UploadSeekableStreamContext(context.Background(), path, stream, contentType)
+}
+
+func (b *BlobStoreApiClient) UploadSeekableStreamContext(ctx context.Context, path string, stream io.ReadSeeker, contentType string) error {
+	start, err := stream.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	end, err := stream.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		if _, err := stream.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+
+		buffer := make([]byte, 512)
+		n, err := io.ReadFull(stream, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		contentType = http.DetectContentType(buffer[:n])
+	}
+
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		if _, err := stream.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		// Hide any Close method, so the transport can't close the stream
+		// before a retry gets to send it again.
+		routed, err := b.route(path)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, "POST", routed, ioutil.NopCloser(newProgressReader(ctx, path, stream, end-start)))
+		if err != nil {
+			return nil, err
+		}
+
+		request.ContentLength = end - start
+		// Signing requests swaps the body for one from GetBody, so it has to
+		// report progress too.
+		request.GetBody = func() (io.ReadCloser, error) {
+			if _, err := stream.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(newProgressReader(ctx, path, stream, end-start)), nil
+		}
+		request.Header.Add("Content-Type", contentType)
+
+		err = b.credentialProvider.AuthorizeRequest(request)
+		return request, err
+	})
+	if err != nil {
+		return err
+	}
+	defer closeResponseBody(response)
+
+	if response.StatusCode != 200 {
+		return NewBlobStoreHttpError("Upload", path, response)
 	}
 
-	response, err := b.http.Do(request)
+	return nil
+}
+
+func (b *BlobStoreApiClient) GetStat(path string) (*BlobFileStat, error) {
+	return b.GetStatContext(context.Background(), path)
+}
+
+func (b *BlobStoreApiClient) GetStatContext(ctx context.Context, path string) (*BlobFileStat, error) {
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		return b.newAuthorizedRequest(ctx, "HEAD", path, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
+	defer closeResponseBody(response)
 
 	baseUrlComponent, err := url.Parse(b.baseUrl)
 	if err != nil {
@@ -195,67 +470,157 @@ func (b *BlobStoreApiClient) GetStat(path string) (*BlobFileStat, error) {
 	stat := NewBlobFileStatFromResponse(baseUrlComponent.Path, response)
 
 	if response.StatusCode != 404 && response.StatusCode != 200 {
-		return nil, NewBlobStoreHttpError("Stat", response)
+		return nil, NewBlobStoreHttpError("Stat", path, response)
 	}
 
 	return &stat, nil
 }
 
 func (b *BlobStoreApiClient) GetFile(path string) (*BlobFile, error) {
-	request, err := b.newAuthorizedRequest("GET", path, nil)
-	if err != nil {
-		return nil, err
-	}
+	return b.GetFileContext(context.Background(), path)
+}
 
-	response, err := b.http.Do(request)
+func (b *BlobStoreApiClient) GetFileContext(ctx context.Context, path string) (*BlobFile, error) {
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		return b.newAuthorizedRequest(ctx, "GET", path, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	baseUrlComponent, err := url.Parse(b.baseUrl)
 	if err != nil {
+		closeResponseBody(response)
 		return nil, err
 	}
 
 	stat := NewBlobFileStatFromResponse(baseUrlComponent.Path, response)
 
 	if response.StatusCode != 200 {
-		return nil, NewBlobStoreHttpError("Download", response)
+		defer closeResponseBody(response)
+		return nil, NewBlobStoreHttpError("Download", path, response)
 	}
 
-	body := response.Body.(io.Reader)
 	rv := BlobFile{
 		stat,
-		body,
+		readCloser{newProgressReader(ctx, path, response.Body, response.ContentLength), response.Body},
 	}
 
 	return &rv, nil
 }
 
+func (b *BlobStoreApiClient) GetFileRange(path string, offset, length int64) (*BlobFile, error) {
+	return b.GetFileRangeContext(context.Background(), path, offset, length)
+}
+
+// Fetches part of a file. A negative length reads from offset to the end of
+// the file, and a negative offset reads that many bytes from the end of the
+// file, ignoring length.
+func (b *BlobStoreApiClient) GetFileRangeContext(ctx context.Context, path string, offset, length int64) (*BlobFile, error) {
+	if offset >= 0 && length == 0 {
+		return nil, errors.New("Cannot request an empty range of a file")
+	}
+
+	byteRange := ""
+	if offset < 0 {
+		byteRange = fmt.Sprintf("bytes=%d", offset)
+	} else if length < 0 {
+		byteRange = fmt.Sprintf("bytes=%d-", offset)
+	} else {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		request, err := b.newAuthorizedRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Range", byteRange)
+		return request, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	baseUrlComponent, err := url.Parse(b.baseUrl)
+	if err != nil {
+		closeResponseBody(response)
+		return nil, err
+	}
+
+	stat := NewBlobFileStatFromResponse(baseUrlComponent.Path, response)
+
+	if response.StatusCode == http.StatusPartialContent {
+		if size, ok := parseContentRangeSize(response.Header.Get("Content-Range")); ok {
+			stat.SizeBytes = size
+		}
+
+		return &BlobFile{stat, readCloser{newProgressReader(ctx, path, response.Body, response.ContentLength), response.Body}}, nil
+	}
+
+	if response.StatusCode != 200 {
+		defer closeResponseBody(response)
+		return nil, NewBlobStoreHttpError("Download", path, response)
+	}
+
+	// The server ignored the range, and sent the whole file instead, so the
+	// requested range has to be cut out of it here.
+	start := offset
+	if offset < 0 {
+		start = int64(stat.SizeBytes) + offset
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, response.Body, start); err != nil && err != io.EOF {
+		response.Body.Close()
+		return nil, err
+	}
+
+	var contents io.Reader = response.Body
+	total := int64(-1)
+	if response.ContentLength >= 0 {
+		total = response.ContentLength - start
+	}
+
+	if offset >= 0 && length > 0 {
+		contents = io.LimitReader(response.Body, length)
+		if total < 0 || length < total {
+			total = length
+		}
+	}
+
+	return &BlobFile{stat, readCloser{newProgressReader(ctx, path, contents, total), response.Body}}, nil
+}
+
 func (b *BlobStoreApiClient) ListPrefix(prefix string, recursive bool) ([]string, error) {
+	return b.ListPrefixContext(context.Background(), prefix, recursive)
+}
+
+func (b *BlobStoreApiClient) ListPrefixContext(ctx context.Context, prefix string, recursive bool) ([]string, error) {
 	paths := make([]string, 0)
 
 	for strings.HasPrefix(prefix, "/") {
 		prefix = prefix[1:]
 	}
 
-	requestUrl := b.route("_dir/" + prefix)
+	query := ""
 	if recursive {
-		requestUrl += "?recursive=true"
+		query = "recursive=true"
 	}
 
-	request, err := b.newAuthorizedRequest("GET", requestUrl, nil)
-	if err != nil {
-		return paths, err
-	}
-
-	response, err := b.http.Do(request)
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		return b.newAuthorizedListRequest(ctx, prefix, query)
+	})
 	if err != nil {
 		return paths, err
 	}
+	defer closeResponseBody(response)
 
 	if response.StatusCode != 200 {
-		return paths, NewBlobStoreHttpError("List", response)
+		return paths, NewBlobStoreHttpError("List", prefix, response)
 	}
 
 	err = json.NewDecoder(response.Body).Decode(&paths)
@@ -267,18 +632,20 @@ func (b *BlobStoreApiClient) ListPrefix(prefix string, recursive bool) ([]string
 }
 
 func (b *BlobStoreApiClient) DeleteFile(path string) error {
-	request, err := b.newAuthorizedRequest("DELETE", path, nil)
-	if err != nil {
-		return err
-	}
+	return b.DeleteFileContext(context.Background(), path)
+}
 
-	response, err := b.http.Do(request)
+func (b *BlobStoreApiClient) DeleteFileContext(ctx context.Context, path string) error {
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		return b.newAuthorizedRequest(ctx, "DELETE", path, nil)
+	})
 	if err != nil {
 		return err
 	}
+	defer closeResponseBody(response)
 
 	if response.StatusCode != 200 {
-		return NewBlobStoreHttpError("Delete", response)
+		return NewBlobStoreHttpError("Delete", path, response)
 	}
 
 	return nil
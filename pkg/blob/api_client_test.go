@@ -3,7 +3,9 @@ package blob
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -40,6 +42,40 @@ func TestNewApiClient(t *testing.T) {
 
 	httpClient := client.http.(*http.Client)
 	assert.Equal(t, time.Second*30, httpClient.Timeout)
+
+	assert.Equal(t, 1, client.retryPolicy.MaxAttempts)
+}
+
+func TestApiClientSetters(t *testing.T) {
+	client := testApiClient()
+
+	client.SetBaseUrl("https://example.org/deeper")
+	assert.Equal(t, "https://example.org/deeper/", client.BaseUrl())
+
+	cred := &credential_provider.DirectCredentialProvider{}
+	client.SetCredentialProvider(cred)
+	assert.Equal(t, cred, client.credentialProvider)
+
+	client.SetTimeout(time.Minute)
+	httpClient := client.http.(*http.Client)
+	assert.Equal(t, time.Minute, httpClient.Timeout)
+}
+
+func TestExplainAuthorization(t *testing.T) {
+	client := testApiClient()
+	client.http = &TestDrivenHttpClient{}
+
+	sources, err := client.ExplainAuthorization(context.Background(), "HEAD", RemoteTestFilename)
+	assert.Nil(t, err)
+	assert.Equal(t, []credential_provider.CredentialSource{
+		{Header: "X-Blobstore-Read-Acl", Value: RemoteTestReadSecret, Provider: "direct credentials"},
+	}, sources)
+
+	sources, err = client.ExplainAuthorization(context.Background(), "DELETE", RemoteTestFilename)
+	assert.Nil(t, err)
+	assert.Equal(t, []credential_provider.CredentialSource{
+		{Header: "X-Blobstore-Write-Acl", Value: RemoteTestWriteSecret, Provider: "direct credentials"},
+	}, sources)
 }
 
 func TestRoute(t *testing.T) {
@@ -56,39 +92,25 @@ func TestRoute(t *testing.T) {
 		{"https://example.org/deeper", "path/to/object", "https://example.org/deeper/path/to/object"},
 		{"https://example.org/deeper/", "/path/to/object", "https://example.org/deeper/path/to/object"},
 		{"https://example.org/deeper/", "path/to/object", "https://example.org/deeper/path/to/object"},
+		{"https://example.org", "path/to/a?b#c", "https://example.org/path/to/a%3Fb%23c"},
+		{"https://example.org", "weird/100%", "https://example.org/weird/100%25"},
+		{"https://example.org", ":colon", "https://example.org/:colon"},
 	}
 
 	for _, ti := range happyCases {
 		api := NewBlobStoreApiClient(ti.BaseUrl, nil)
 
-		assert.Equal(t, ti.FinalUrl, api.route(ti.PathComponent))
+		route, err := api.route(ti.PathComponent)
+		assert.Nil(t, err)
+		assert.Equal(t, ti.FinalUrl, route)
 	}
 }
 
 func TestRouteErrors(t *testing.T) {
-	errorCases := []struct {
-		BaseUrl       string
-		PathComponent string
-		PanicMessage  string
-	}{
-		{":broken", "", "parse \":broken/\": missing protocol scheme"},
-		{"https://example.org", ":broken", "parse \":broken\": missing protocol scheme"},
-	}
+	api := NewBlobStoreApiClient(":broken", nil)
 
-	for _, ti := range errorCases {
-		api := NewBlobStoreApiClient(ti.BaseUrl, nil)
-		func() {
-			defer func() {
-				r := recover()
-				if r == nil {
-					t.Errorf("Failed to produce panic: %s", ti.PanicMessage)
-				} else {
-					assert.Equal(t, ti.PanicMessage, r.(error).Error())
-				}
-			}()
-			api.route(ti.PathComponent)
-		}()
-	}
+	_, err := api.route("path")
+	assert.Equal(t, "parse \":broken/\": missing protocol scheme", err.Error())
 }
 
 func TestUploadStream(t *testing.T) {
@@ -100,8 +122,7 @@ func TestUploadStream(t *testing.T) {
 		assert.Equal(t, RemoteTestUploadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
 		assert.Equal(t, RemoteTestFileManualMimeType, request.Header.Get("Content-Type"))
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -127,8 +148,7 @@ func TestUploadStreamNoContentType(t *testing.T) {
 		assert.Equal(t, RemoteTestUploadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
 		assert.Equal(t, RemoteTestFileAutomaticMimeType, request.Header.Get("Content-Type"))
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -165,6 +185,80 @@ func TestUploadStreamFails(t *testing.T) {
 
 	err = client.UploadStream(RemoteTestFilename, bufio.NewReader(file), RemoteTestFileManualMimeType)
 	assert.Equal(t, "Blobstore Upload Failed (404): {\"code\":\"NotFound\",\"message\":\"File not found\"}", err.Error())
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestUploadStreamNotRetried(t *testing.T) {
+	client := testApiClient()
+	client.SetRetryPolicy(RetryPolicy{3, time.Millisecond, time.Millisecond})
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		response := http.Response{
+			StatusCode: 502,
+		}
+		return &response, nil
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+
+	err := client.UploadStream(RemoteTestFilename, bufio.NewReader(strings.NewReader("abc")), RemoteTestFileManualMimeType)
+	assert.Equal(t, "Blobstore Upload Failed (502)", err.Error())
+}
+
+func TestUploadSeekableStreamRetries(t *testing.T) {
+	client := testApiClient()
+	client.SetRetryPolicy(RetryPolicy{3, time.Millisecond, time.Millisecond})
+
+	file, err := os.Open(LocalTestFilePath)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	expectedBody, err := ioutil.ReadFile(LocalTestFilePath)
+	assert.Nil(t, err)
+
+	failMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		body, err := ioutil.ReadAll(request.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedBody, body)
+
+		response := http.Response{
+			StatusCode: 503,
+		}
+		return &response, nil
+	}
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		assert.Equal(t, RemoteTestUploadHttpMethod, request.Method)
+		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
+		assert.Equal(t, RemoteTestFileAutomaticMimeType, request.Header.Get("Content-Type"))
+		assert.Equal(t, int64(len(expectedBody)), request.ContentLength)
+
+		body, err := ioutil.ReadAll(request.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedBody, body)
+
+		// The body can be produced again, for redirects and request signing.
+		bodyAgain, err := request.GetBody()
+		assert.Nil(t, err)
+
+		body, err = ioutil.ReadAll(bodyAgain)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedBody, body)
+
+		response := http.Response{
+			StatusCode: 200,
+		}
+		return &response, nil
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{failMock, httpMock}}
+
+	err = client.UploadSeekableStream(RemoteTestFilename, file, "")
+	assert.Nil(t, err)
 }
 
 func TestGetFile(t *testing.T) {
@@ -175,8 +269,7 @@ func TestGetFile(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		file, err := os.Open(LocalTestFilePath)
 		assert.Nil(t, err)
@@ -208,6 +301,27 @@ func TestGetFile(t *testing.T) {
 	assert.Equal(t, expectedBody, body)
 }
 
+func TestGetFileContext(t *testing.T) {
+	client := testApiClient()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		assert.Equal(t, ctx, request.Context())
+
+		// Mimic http.Client, which refuses to send requests whose context
+		// has already been cancelled.
+		cancel()
+		return nil, request.Context().Err()
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+
+	_, err := client.GetFileContext(ctx, RemoteTestFilename)
+	assert.Equal(t, context.Canceled, err)
+}
+
 func TestGetFileFails(t *testing.T) {
 	client := testApiClient()
 
@@ -231,6 +345,174 @@ func TestGetFileFails(t *testing.T) {
 
 	_, err = client.GetFile(RemoteTestFilename)
 	assert.Equal(t, "Blobstore Download Failed (404): {\"code\":\"NotFound\",\"message\":\"File not found\"}", err.Error())
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestGetFileRetries(t *testing.T) {
+	client := testApiClient()
+	client.SetRetryPolicy(RetryPolicy{3, time.Millisecond, time.Millisecond})
+
+	errorMock := func(params ...interface{}) (*http.Response, error) {
+		return nil, errors.New("connection reset by peer")
+	}
+
+	unavailableMock := func(params ...interface{}) (*http.Response, error) {
+		response := http.Response{
+			StatusCode: 503,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}
+		response.Header.Set("Retry-After", "0")
+		return &response, nil
+	}
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
+		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
+
+		response := http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("abc")),
+			Request:    request,
+		}
+		return &response, nil
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{errorMock, unavailableMock, httpMock}}
+
+	blobFile, err := client.GetFile(RemoteTestFilename)
+	assert.Nil(t, err)
+
+	body, err := ioutil.ReadAll(blobFile.Contents)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", string(body))
+}
+
+func TestGetFileRetriesExhausted(t *testing.T) {
+	client := testApiClient()
+	client.SetRetryPolicy(RetryPolicy{2, time.Millisecond, time.Millisecond})
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		response := http.Response{
+			StatusCode: 500,
+			Body:       ioutil.NopCloser(strings.NewReader("broken")),
+			Request:    request,
+		}
+		return &response, nil
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock, httpMock}}
+
+	_, err := client.GetFile(RemoteTestFilename)
+	assert.Equal(t, "Blobstore Download Failed (500): broken", err.Error())
+}
+
+func TestGetFileRange(t *testing.T) {
+	cases := []struct {
+		Offset int64
+		Length int64
+		Range  string
+	}{
+		{0, 10, "bytes=0-9"},
+		{100, 1, "bytes=100-100"},
+		{100, -1, "bytes=100-"},
+		{-20, 0, "bytes=-20"},
+	}
+
+	for _, ti := range cases {
+		client := testApiClient()
+
+		httpMock := func(params ...interface{}) (*http.Response, error) {
+			request := params[0].(*http.Request)
+
+			assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
+			assert.Equal(t, RemoteTestFileUrl, request.URL.String())
+			assert.Equal(t, ti.Range, request.Header.Get("Range"))
+
+			response := http.Response{
+				StatusCode: 206,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("abc")),
+				Request:    request,
+			}
+			response.Header.Set("Content-Length", "3")
+			response.Header.Set("Content-Range", "bytes 0-2/1024")
+			return &response, nil
+		}
+
+		client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+
+		blobFile, err := client.GetFileRange(RemoteTestFilename, ti.Offset, ti.Length)
+		assert.Nil(t, err)
+		assert.Equal(t, 1024, blobFile.Info.SizeBytes)
+
+		body, err := ioutil.ReadAll(blobFile.Contents)
+		assert.Nil(t, err)
+		assert.Equal(t, "abc", string(body))
+	}
+}
+
+func TestGetFileRangeIgnoredByServer(t *testing.T) {
+	cases := []struct {
+		Offset   int64
+		Length   int64
+		Expected string
+	}{
+		{2, 3, "cde"},
+		{2, -1, "cdefghij"},
+		{-3, 0, "hij"},
+	}
+
+	for _, ti := range cases {
+		client := testApiClient()
+
+		httpMock := func(params ...interface{}) (*http.Response, error) {
+			request := params[0].(*http.Request)
+
+			response := http.Response{
+				StatusCode: 200,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("abcdefghij")),
+				Request:    request,
+			}
+			response.Header.Set("Content-Length", "10")
+			return &response, nil
+		}
+
+		client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+
+		blobFile, err := client.GetFileRange(RemoteTestFilename, ti.Offset, ti.Length)
+		assert.Nil(t, err)
+
+		body, err := ioutil.ReadAll(blobFile.Contents)
+		assert.Nil(t, err)
+		assert.Equal(t, ti.Expected, string(body))
+	}
+}
+
+func TestGetFileRangeNotSatisfiable(t *testing.T) {
+	client := testApiClient()
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		response := http.Response{
+			StatusCode: 416,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    request,
+		}
+		return &response, nil
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+
+	_, err := client.GetFileRange(RemoteTestFilename, 2048, 10)
+	assert.Equal(t, "Blobstore Download Failed (416): ", err.Error())
+	assert.True(t, errors.Is(err, ErrRangeNotSatisfiable))
 }
 
 func TestGetStat(t *testing.T) {
@@ -241,8 +523,7 @@ func TestGetStat(t *testing.T) {
 
 		assert.Equal(t, RemoteTestStatHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -252,6 +533,8 @@ func TestGetStat(t *testing.T) {
 		response.Header = make(map[string][]string)
 		response.Header.Set("Content-Type", RemoteTestFileManualMimeType)
 		response.Header.Set("Content-Length", "1024")
+		response.Header.Set("ETag", "\"abc123\"")
+		response.Header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
 
 		return &response, nil
 	}
@@ -266,6 +549,8 @@ func TestGetStat(t *testing.T) {
 	assert.Equal(t, RemoteTestFileManualMimeType, fileStat.MimeType)
 	assert.Equal(t, 1024, fileStat.SizeBytes)
 	assert.Equal(t, true, fileStat.Exists)
+	assert.Equal(t, "\"abc123\"", fileStat.ETag)
+	assert.Equal(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), fileStat.LastModified)
 }
 
 func TestGetStatLongerFilename(t *testing.T) {
@@ -276,8 +561,7 @@ func TestGetStatLongerFilename(t *testing.T) {
 
 		assert.Equal(t, RemoteTestStatHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestDeepFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -311,8 +595,7 @@ func TestGetStatDoesNotExist(t *testing.T) {
 
 		assert.Equal(t, RemoteTestStatHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 404,
@@ -349,6 +632,7 @@ func TestGetStatFails(t *testing.T) {
 	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
 	fileStat, err := client.GetStat(RemoteTestFilename)
 	assert.Equal(t, "Blobstore Stat Failed (403)", err.Error())
+	assert.True(t, errors.Is(err, ErrForbidden))
 	assert.Nil(t, fileStat)
 }
 
@@ -360,8 +644,7 @@ func TestListPrefix(t *testing.T) {
 
 		assert.Equal(t, RemoteTestListDirHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestListDirUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		files := []string{"file-1", "file-2", "file-3"}
 		filesBytes, err := json.Marshal(files)
@@ -389,8 +672,7 @@ func TestListPrefixRecursive(t *testing.T) {
 
 		assert.Equal(t, RemoteTestListDirHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestListDirRecursiveUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		files := []string{"file-1", "file-2", "file-3"}
 		filesBytes, err := json.Marshal(files)
@@ -436,8 +718,7 @@ func TestDeleteFile(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDeleteHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -468,4 +749,51 @@ func TestDeleteFileFails(t *testing.T) {
 	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
 	err := client.DeleteFile(RemoteTestFilename)
 	assert.Equal(t, "Blobstore Delete Failed (403): {\"code\":\"PermissionDenied\",\"message\":\"Cannot delete\"}", err.Error())
+	assert.True(t, errors.Is(err, ErrForbidden))
+}
+
+type trackedBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestResponseBodiesClosed(t *testing.T) {
+	cases := []struct {
+		Name       string
+		StatusCode int
+		Body       string
+		Call       func(client *BlobStoreApiClient) error
+	}{
+		{"Delete", 200, "", func(c *BlobStoreApiClient) error { return c.DeleteFile(RemoteTestFilename) }},
+		{"Delete Fails", 403, "denied", func(c *BlobStoreApiClient) error { return c.DeleteFile(RemoteTestFilename) }},
+		{"Stat", 200, "", func(c *BlobStoreApiClient) error { _, err := c.GetStat(RemoteTestFilename); return err }},
+		{"List", 200, "[]", func(c *BlobStoreApiClient) error { _, err := c.ListPrefix("prefix", false); return err }},
+		{"List Fails", 403, "denied", func(c *BlobStoreApiClient) error { _, err := c.ListPrefix("prefix", false); return err }},
+		{"Download Fails", 404, "missing", func(c *BlobStoreApiClient) error { _, err := c.GetFile(RemoteTestFilename); return err }},
+		{"Range Fails", 403, "denied", func(c *BlobStoreApiClient) error { _, err := c.GetFileRange(RemoteTestFilename, 0, 1); return err }},
+		{"Upload", 200, "", func(c *BlobStoreApiClient) error {
+			return c.UploadStream(RemoteTestFilename, bufio.NewReader(strings.NewReader("abc")), "text/plain")
+		}},
+		{"Upload Seekable", 200, "", func(c *BlobStoreApiClient) error {
+			return c.UploadSeekableStream(RemoteTestFilename, strings.NewReader("abc"), "text/plain")
+		}},
+	}
+
+	for _, ti := range cases {
+		client := testApiClient()
+
+		body := &trackedBody{strings.NewReader(ti.Body), false}
+		client.http = &TestDrivenHttpClient{[]HttpMockedMethod{func(params ...interface{}) (*http.Response, error) {
+			return &http.Response{StatusCode: ti.StatusCode, Header: http.Header{}, Body: body, Request: params[0].(*http.Request)}, nil
+		}}}
+
+		ti.Call(client)
+		assert.True(t, body.closed, ti.Name)
+		assert.Equal(t, 0, body.Len(), ti.Name)
+	}
 }
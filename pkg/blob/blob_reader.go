@@ -0,0 +1,150 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// BlobReader reads a file on the blobstore in place, using ranged requests,
+// so that only the parts of the file actually read are downloaded.
+type BlobReader struct {
+	ctx       context.Context
+	apiClient IBlobStoreApiClient
+	path      string
+	size      int64
+
+	offset int64
+	body   io.ReadCloser
+}
+
+func NewBlobReader(ctx context.Context, apiClient IBlobStoreApiClient, path string) (*BlobReader, error) {
+	stat, err := apiClient.GetStatContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !stat.Exists {
+		return nil, &BlobStoreHttpError{
+			Operation:  "Stat",
+			Path:       path,
+			StatusCode: http.StatusNotFound,
+		}
+	}
+
+	rv := BlobReader{
+		ctx:       ctx,
+		apiClient: apiClient,
+		path:      path,
+		size:      int64(stat.SizeBytes),
+	}
+
+	return &rv, nil
+}
+
+func (r *BlobReader) Size() int64 {
+	return r.size
+}
+
+func (r *BlobReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Cannot read from a negative offset")
+	}
+
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	want := int64(len(p))
+	if off+want > r.size {
+		want = r.size - off
+	}
+
+	f, err := r.apiClient.GetFileRangeContext(r.ctx, r.path, off, want)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Contents.Close()
+
+	n, err := io.ReadFull(f.Contents, p[:want])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	if want < int64(len(p)) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Sequential reads share a single request for the rest of the file, which is
+// only reopened when a seek moves somewhere else.
+func (r *BlobReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		f, err := r.apiClient.GetFileRangeContext(r.ctx, r.path, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+
+		r.body = f.Contents
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func (r *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.offset, errors.New("Invalid seek whence")
+	}
+
+	if offset < 0 {
+		return r.offset, errors.New("Cannot seek to a negative offset")
+	}
+
+	if offset != r.offset {
+		r.closeBody()
+		r.offset = offset
+	}
+
+	return r.offset, nil
+}
+
+func (r *BlobReader) Close() error {
+	return r.closeBody()
+}
+
+func (r *BlobReader) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,128 @@
+package blob
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func testBlobReader(t *testing.T, contents []byte) (*BlobReader, *blobtest.Server) {
+	server, client := testServerClient(blobtest.Config{})
+	server.Put(RemoteTestFilename, contents, "")
+
+	reader, err := NewBlobReader(context.Background(), client.apiClient, RemoteTestFilename)
+	assert.Nil(t, err)
+
+	return reader, server
+}
+
+func TestBlobReaderReadAt(t *testing.T) {
+	reader, server := testBlobReader(t, []byte("abcdefghij"))
+	defer server.Close()
+	assert.Equal(t, int64(10), reader.Size())
+
+	buffer := make([]byte, 3)
+	n, err := reader.ReadAt(buffer, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "efg", string(buffer))
+
+	n, err = reader.ReadAt(buffer, 8)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "ij", string(buffer[:n]))
+
+	n, err = reader.ReadAt(buffer, 10)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, n)
+
+	assert.Equal(t, 3, server.Requests())
+}
+
+func TestBlobReaderReadSeek(t *testing.T) {
+	reader, server := testBlobReader(t, []byte("abcdefghij"))
+	defer server.Close()
+	defer reader.Close()
+
+	buffer := make([]byte, 3)
+	n, err := reader.Read(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", string(buffer[:n]))
+
+	n, err = reader.Read(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, "def", string(buffer[:n]))
+
+	// Sequential reads should reuse the same request.
+	assert.Equal(t, 2, server.Requests())
+
+	offset, err := reader.Seek(-2, io.SeekEnd)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), offset)
+
+	rest, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "ij", string(rest))
+	assert.Equal(t, 3, server.Requests())
+
+	_, err = reader.Seek(-1, io.SeekStart)
+	assert.NotNil(t, err)
+}
+
+func TestBlobReaderZipArchive(t *testing.T) {
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+	for _, name := range []string{"first.txt", "second.txt"} {
+		f, err := writer.Create(name)
+		assert.Nil(t, err)
+		f.Write([]byte(strings.Repeat(name, 100)))
+	}
+	assert.Nil(t, writer.Close())
+
+	reader, server := testBlobReader(t, archive.Bytes())
+	defer server.Close()
+
+	zipReader, err := zip.NewReader(reader, reader.Size())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(zipReader.File))
+
+	f, err := zipReader.File[1].Open()
+	assert.Nil(t, err)
+
+	contents, err := ioutil.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("second.txt", 100), string(contents))
+}
+
+func TestBlobReaderDoesNotExist(t *testing.T) {
+	client := testApiClient()
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		response := http.Response{
+			StatusCode: 404,
+			Request:    request,
+		}
+		return &response, nil
+	}
+
+	client.http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+
+	_, err := NewBlobReader(context.Background(), client, RemoteTestFilename)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -2,6 +2,7 @@ package blob
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -20,67 +21,162 @@ const BlobStoreUrlScheme string = "blob"
 
 type BlobStoreClient struct {
 	apiClient IBlobStoreApiClient
+
+	transferOptions TransferOptions
 }
 
 type IBlobStoreClient interface {
 	Cat(src *url.URL) error
+	CatContext(ctx context.Context, src *url.URL) error
 	Copy(src *url.URL, dst *url.URL, force bool) error
+	CopyContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) error
+	CopyPrefix(src *url.URL, dst *url.URL, force bool) (*TransferSummary, error)
+	CopyPrefixContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) (*TransferSummary, error)
+	CopyResumable(src *url.URL, dst *url.URL, force bool) error
+	CopyResumableContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) error
+
+	Move(src *url.URL, dst *url.URL, force bool) error
+	MoveContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) error
+	MoveRecursive(src *url.URL, dst *url.URL, force bool) (*TransferSummary, error)
+	MoveRecursiveContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) (*TransferSummary, error)
 
 	UploadFile(url_ *url.URL, source string, contentType string) error
+	UploadFileContext(ctx context.Context, url_ *url.URL, source string, contentType string) error
+	UploadDirectory(dst *url.URL, source string, force bool) (*TransferSummary, error)
+	UploadDirectoryContext(ctx context.Context, dst *url.URL, source string, force bool) (*TransferSummary, error)
 
 	GetFileContents(url_ *url.URL) (string, error)
+	GetFileContentsContext(ctx context.Context, url_ *url.URL) (string, error)
 	DownloadFile(url_ *url.URL, dest string) error
+	DownloadFileContext(ctx context.Context, url_ *url.URL, dest string) error
+	DownloadPrefix(src *url.URL, dest string, force bool) (*TransferSummary, error)
+	DownloadPrefixContext(ctx context.Context, src *url.URL, dest string, force bool) (*TransferSummary, error)
+	DownloadFileResumable(url_ *url.URL, dest string) error
+	DownloadFileResumableContext(ctx context.Context, url_ *url.URL, dest string) error
+
+	Open(url_ *url.URL) (*BlobReader, error)
+	OpenContext(ctx context.Context, url_ *url.URL) (*BlobReader, error)
 
 	StatFile(url_ *url.URL) (*BlobFileStat, error)
+	StatFileContext(ctx context.Context, url_ *url.URL) (*BlobFileStat, error)
 
 	AppendStream(url_ *url.URL, stream *bufio.Reader) error
+	AppendStreamContext(ctx context.Context, url_ *url.URL, stream *bufio.Reader) error
 	AppendString(url_ *url.URL, value string) error
+	AppendStringContext(ctx context.Context, url_ *url.URL, value string) error
 	AppendFile(url_ *url.URL, source string) error
+	AppendFileContext(ctx context.Context, url_ *url.URL, source string) error
 
 	ListPrefix(prefix string, recursive bool) ([]string, error)
+	ListPrefixContext(ctx context.Context, prefix string, recursive bool) ([]string, error)
+	ListPrefixStat(prefix string, recursive bool) ([]BlobFileStat, error)
+	ListPrefixStatContext(ctx context.Context, prefix string, recursive bool) ([]BlobFileStat, error)
+	Glob(pattern string) ([]string, error)
+	GlobContext(ctx context.Context, pattern string) ([]string, error)
 
 	DeleteFile(url_ *url.URL) error
+	DeleteFileContext(ctx context.Context, url_ *url.URL) error
+	DeleteFiles(files []*url.URL) (*TransferSummary, error)
+	DeleteFilesContext(ctx context.Context, files []*url.URL) (*TransferSummary, error)
+
+	Transfer(jobs []TransferJob) (*TransferSummary, error)
+	TransferContext(ctx context.Context, jobs []TransferJob) (*TransferSummary, error)
+
+	PlanSync(src *url.URL, dst *url.URL, options SyncOptions) (*SyncPlan, error)
+	PlanSyncContext(ctx context.Context, src *url.URL, dst *url.URL, options SyncOptions) (*SyncPlan, error)
+	ApplySyncPlan(plan *SyncPlan) (*TransferSummary, error)
+	ApplySyncPlanContext(ctx context.Context, plan *SyncPlan) (*TransferSummary, error)
 
 	Exists(url_ *url.URL) (bool, error)
+	ExistsContext(ctx context.Context, url_ *url.URL) (bool, error)
 }
 
 func NewBlobStoreClient(url string, credentialProvider credential_provider.ICredentialProvider) *BlobStoreClient {
 	apiClient := NewBlobStoreApiClient(url, credentialProvider)
+	return NewBlobStoreClientWithApiClient(apiClient)
+}
 
+func NewBlobStoreClientWithApiClient(apiClient IBlobStoreApiClient) *BlobStoreClient {
 	return &BlobStoreClient{
-		apiClient,
+		apiClient:       apiClient,
+		transferOptions: DefaultTransferOptions(),
 	}
 }
 
+// Applies to every operation that works on many files at once.
+func (b *BlobStoreClient) SetTransferOptions(options TransferOptions) {
+	b.transferOptions = options
+}
+
+func (b *BlobStoreClient) newTransferManager() *TransferManager {
+	return NewTransferManager(b, b.transferOptions)
+}
+
 func (b *BlobStoreClient) Copy(src *url.URL, dst *url.URL, force bool) error {
-	if src.Scheme == BlobStoreUrlScheme && dst.Scheme == BlobStoreUrlScheme {
-		return errors.New("No support for copying files in the blobstore directly")
-	}
+	return b.CopyContext(context.Background(), src, dst, force)
+}
 
+func (b *BlobStoreClient) CopyContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) error {
 	if src.Scheme != BlobStoreUrlScheme && dst.Scheme != BlobStoreUrlScheme {
 		return errors.New("Must provide at least one blob:/ path to upload to or download from")
 	}
 
-	if force == false {
-		if exists, err := b.Exists(dst); err != nil {
-			return err
-		} else if exists {
-			if dst.Scheme == BlobStoreUrlScheme {
-				return errors.New("Destination file already exists on blobstore; use --force to overwrite")
-			}
+	if src.Scheme == BlobStoreUrlScheme && dst.Scheme == BlobStoreUrlScheme && sameBlobPath(src, dst) {
+		return errors.New("Source and destination are the same file")
+	}
 
-			return errors.New("Destination file already exists on local machine; use --force to overwrite")
-		}
+	if err := b.checkOverwrite(ctx, dst, force); err != nil {
+		return err
 	}
 
-	if src.Scheme == BlobStoreUrlScheme {
-		return b.DownloadFile(src, dst.Path)
+	if src.Scheme == BlobStoreUrlScheme && dst.Scheme == BlobStoreUrlScheme {
+		return b.copyBlobContext(ctx, src, dst)
+	} else if src.Scheme == BlobStoreUrlScheme {
+		return b.DownloadFileContext(ctx, src, dst.Path)
 	} else {
-		return b.UploadFile(dst, src.Path, "")
+		return b.UploadFileContext(ctx, dst, src.Path, "")
+	}
+}
+
+func (b *BlobStoreClient) CopyResumable(src *url.URL, dst *url.URL, force bool) error {
+	return b.CopyResumableContext(context.Background(), src, dst, force)
+}
+
+func (b *BlobStoreClient) CopyResumableContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) error {
+	if src.Scheme != BlobStoreUrlScheme || dst.Scheme == BlobStoreUrlScheme {
+		return errors.New("Can only continue downloads from blob:/ to the local machine")
+	}
+
+	if err := b.checkOverwrite(ctx, dst, force); err != nil {
+		return err
+	}
+
+	return b.DownloadFileResumableContext(ctx, src, dst.Path)
+}
+
+func (b *BlobStoreClient) checkOverwrite(ctx context.Context, dst *url.URL, force bool) error {
+	if force {
+		return nil
+	}
+
+	if exists, err := b.ExistsContext(ctx, dst); err != nil {
+		return err
+	} else if exists {
+		if dst.Scheme == BlobStoreUrlScheme {
+			return errors.New("Destination file already exists on blobstore; use --force to overwrite")
+		}
+
+		return errors.New("Destination file already exists on local machine; use --force to overwrite")
 	}
+
+	return nil
 }
 
 func (b *BlobStoreClient) UploadFile(url_ *url.URL, source string, contentType string) error {
+	return b.UploadFileContext(context.Background(), url_, source, contentType)
+}
+
+func (b *BlobStoreClient) UploadFileContext(ctx context.Context, url_ *url.URL, source string, contentType string) error {
 	file, err := os.Open(source)
 	defer file.Close()
 
@@ -88,15 +184,19 @@ func (b *BlobStoreClient) UploadFile(url_ *url.URL, source string, contentType s
 		return err
 	}
 
-	fileReader := bufio.NewReader(file)
-	return b.apiClient.UploadStream(url_.Path, fileReader, contentType)
+	return b.apiClient.UploadSeekableStreamContext(ctx, url_.Path, file, contentType)
 }
 
 func (b *BlobStoreClient) GetFileContents(url_ *url.URL) (string, error) {
-	file, err := b.apiClient.GetFile(url_.Path)
+	return b.GetFileContentsContext(context.Background(), url_)
+}
+
+func (b *BlobStoreClient) GetFileContentsContext(ctx context.Context, url_ *url.URL) (string, error) {
+	file, err := b.apiClient.GetFileContext(ctx, url_.Path)
 	if err != nil {
 		return "", err
 	}
+	defer file.Contents.Close()
 
 	bodyBytes, err := ioutil.ReadAll(file.Contents)
 	if err != nil {
@@ -107,55 +207,123 @@ func (b *BlobStoreClient) GetFileContents(url_ *url.URL) (string, error) {
 }
 
 func (b *BlobStoreClient) DownloadFile(url_ *url.URL, dest string) error {
-	str, err := b.GetFileContents(url_)
+	return b.DownloadFileContext(context.Background(), url_, dest)
+}
+
+func (b *BlobStoreClient) DownloadFileContext(ctx context.Context, url_ *url.URL, dest string) error {
+	file, err := b.apiClient.GetFileContext(ctx, url_.Path)
 	if err != nil {
 		return err
 	}
+	defer file.Contents.Close()
 
+	return writeFileAtomically(dest, file.Contents)
+}
+
+// Streams into a temporary file beside dest, and only renames it into place
+// once everything has been written, so a failed download never leaves a
+// truncated file where the real one should be.
+func writeFileAtomically(dest string, r io.Reader) error {
 	destDirectory := filepath.Dir(dest)
-	err = os.MkdirAll(destDirectory, 0755)
+	err := os.MkdirAll(destDirectory, 0755)
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(destDirectory, "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tempFile, r)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(tempFile.Name(), dest)
+	}
+
 	if err != nil {
+		os.Remove(tempFile.Name())
 		return err
 	}
 
-	err = ioutil.WriteFile(dest, []byte(str), 0644)
-	return err
+	return nil
+}
+
+func (b *BlobStoreClient) Open(url_ *url.URL) (*BlobReader, error) {
+	return b.OpenContext(context.Background(), url_)
+}
+
+func (b *BlobStoreClient) OpenContext(ctx context.Context, url_ *url.URL) (*BlobReader, error) {
+	return NewBlobReader(ctx, b.apiClient, url_.Path)
 }
 
 func (b *BlobStoreClient) Cat(src *url.URL) error {
+	return b.CatContext(context.Background(), src)
+}
+
+func (b *BlobStoreClient) CatContext(ctx context.Context, src *url.URL) error {
 	if src.Scheme != BlobStoreUrlScheme {
 		return errors.New("Must download files from blob:/")
 	}
 
-	str, err := b.GetFileContents(src)
+	file, err := b.apiClient.GetFileContext(ctx, src.Path)
 	if err != nil {
 		return err
 	}
+	defer file.Contents.Close()
+
+	if _, err := io.Copy(os.Stdout, file.Contents); err != nil {
+		return err
+	}
 
-	fmt.Println(str)
+	fmt.Println()
 	return nil
 }
 
 func (b *BlobStoreClient) StatFile(url_ *url.URL) (*BlobFileStat, error) {
-	return b.apiClient.GetStat(url_.Path)
+	return b.StatFileContext(context.Background(), url_)
+}
+
+func (b *BlobStoreClient) StatFileContext(ctx context.Context, url_ *url.URL) (*BlobFileStat, error) {
+	return b.apiClient.GetStatContext(ctx, url_.Path)
 }
 
 func (b *BlobStoreClient) AppendStream(url_ *url.URL, stream *bufio.Reader) error {
-	f, err := b.apiClient.GetFile(url_.Path)
+	return b.AppendStreamContext(context.Background(), url_, stream)
+}
+
+func (b *BlobStoreClient) AppendStreamContext(ctx context.Context, url_ *url.URL, stream *bufio.Reader) error {
+	f, err := b.apiClient.GetFileContext(ctx, url_.Path)
 	if err != nil {
 		return err
 	}
+	defer f.Contents.Close()
 
 	multiStream := bufio.NewReader(io.MultiReader(f.Contents, stream))
-	return b.apiClient.UploadStream(url_.Path, multiStream, f.Info.MimeType)
+	return b.apiClient.UploadStreamContext(ctx, url_.Path, multiStream, f.Info.MimeType)
 }
 
 func (b *BlobStoreClient) AppendString(url_ *url.URL, value string) error {
+	return b.AppendStringContext(context.Background(), url_, value)
+}
+
+func (b *BlobStoreClient) AppendStringContext(ctx context.Context, url_ *url.URL, value string) error {
 	stringReader := bufio.NewReader(strings.NewReader(value))
-	return b.AppendStream(url_, stringReader)
+	return b.AppendStreamContext(ctx, url_, stringReader)
 }
 
 func (b *BlobStoreClient) AppendFile(url_ *url.URL, source string) error {
+	return b.AppendFileContext(context.Background(), url_, source)
+}
+
+func (b *BlobStoreClient) AppendFileContext(ctx context.Context, url_ *url.URL, source string) error {
 	file, err := os.Open(source)
 	defer file.Close()
 
@@ -164,20 +332,40 @@ func (b *BlobStoreClient) AppendFile(url_ *url.URL, source string) error {
 	}
 
 	fileReader := bufio.NewReader(file)
-	return b.AppendStream(url_, fileReader)
+	return b.AppendStreamContext(ctx, url_, fileReader)
 }
 
 func (b *BlobStoreClient) ListPrefix(prefix string, recursive bool) ([]string, error) {
-	return b.apiClient.ListPrefix(prefix, recursive)
+	return b.ListPrefixContext(context.Background(), prefix, recursive)
+}
+
+func (b *BlobStoreClient) ListPrefixContext(ctx context.Context, prefix string, recursive bool) ([]string, error) {
+	return b.apiClient.ListPrefixContext(ctx, prefix, recursive)
+}
+
+func (b *BlobStoreClient) ListPrefixStat(prefix string, recursive bool) ([]BlobFileStat, error) {
+	return b.ListPrefixStatContext(context.Background(), prefix, recursive)
+}
+
+func (b *BlobStoreClient) ListPrefixStatContext(ctx context.Context, prefix string, recursive bool) ([]BlobFileStat, error) {
+	return b.apiClient.ListPrefixStatContext(ctx, prefix, recursive)
 }
 
 func (b *BlobStoreClient) DeleteFile(url *url.URL) error {
-	return b.apiClient.DeleteFile(url.Path)
+	return b.DeleteFileContext(context.Background(), url)
+}
+
+func (b *BlobStoreClient) DeleteFileContext(ctx context.Context, url *url.URL) error {
+	return b.apiClient.DeleteFileContext(ctx, url.Path)
 }
 
 func (b *BlobStoreClient) Exists(url_ *url.URL) (bool, error) {
+	return b.ExistsContext(context.Background(), url_)
+}
+
+func (b *BlobStoreClient) ExistsContext(ctx context.Context, url_ *url.URL) (bool, error) {
 	if url_.Scheme == "blob" {
-		f, err := b.StatFile(url_)
+		f, err := b.StatFileContext(ctx, url_)
 		if err != nil {
 			return false, err
 		}
@@ -3,7 +3,9 @@ package blob
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 import (
@@ -21,6 +24,7 @@ import (
 
 
 import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
 	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
 )
 
@@ -52,6 +56,17 @@ func (t *TestDrivenHttpClient) r(args ...interface{}) (*http.Response, error) {
 	return t.mockedCalls[0](args...)
 }
 
+// Only the ACL needed for the request's method should ever be sent.
+func assertAclHeaders(t *testing.T, request *http.Request) {
+	if request.Method == "GET" || request.Method == "HEAD" {
+		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
+		assert.False(t, credential_provider.HasWriteAclHeader(request))
+	} else {
+		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assert.False(t, credential_provider.HasReadAclHeader(request))
+	}
+}
+
 const (
 	LocalTestFilePath = "../../Makefile"
 
@@ -83,6 +98,38 @@ func testClient() *BlobStoreClient {
 	return NewBlobStoreClient(RemoteTestBaseUrl, &cred)
 }
 
+// A client for an in-process blobstore, for tests that need one that keeps
+// what's uploaded to it.
+func testServerClient(config blobtest.Config) (*blobtest.Server, *BlobStoreClient) {
+	config.ReadAcl = RemoteTestReadSecret
+	config.WriteAcl = RemoteTestWriteSecret
+	server := blobtest.NewServer(config)
+
+	cred := credential_provider.DirectCredentialProvider{
+		ReadAcl:  RemoteTestReadSecret,
+		WriteAcl: RemoteTestWriteSecret,
+	}
+	return server, NewBlobStoreClient(server.URL, &cred)
+}
+
+func storedContents(server *blobtest.Server, key string) string {
+	object, ok := server.Get(key)
+	if !ok {
+		return ""
+	}
+
+	return string(object.Contents)
+}
+
+func storedContentType(server *blobtest.Server, key string) string {
+	object, ok := server.Get(key)
+	if !ok {
+		return ""
+	}
+
+	return object.ContentType
+}
+
 func TestMain(m *testing.M) {
 	var err error
 	RemoteTestURL, err = url.Parse(fmt.Sprintf("%s:/%s", BlobStoreUrlScheme, RemoteTestFilename))
@@ -135,8 +182,7 @@ func TestDownloadRequest(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		file, err := os.Open(LocalTestFilePath)
 		assert.Nil(t, err)
@@ -183,8 +229,7 @@ func TestDownloadRequestNonExistentDirectory(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		file, err := os.Open(LocalTestFilePath)
 		assert.Nil(t, err)
@@ -224,6 +269,75 @@ func TestDownloadRequestNonExistentDirectory(t *testing.T) {
 	assert.Equal(t, expectedBody, body)
 }
 
+type failingReader struct{}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestDownloadRequestFailsPartWay(t *testing.T) {
+	var api *BlobStoreClient = testClient()
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		bodyReader := io.MultiReader(strings.NewReader("partial contents"), &failingReader{})
+
+		response := http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bodyReader),
+			Request:    request,
+		}
+		return &response, nil
+	}
+
+	api.apiClient.(*BlobStoreApiClient).http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+	tempDir, err := ioutil.TempDir("", "")
+	defer os.RemoveAll(tempDir)
+
+	assert.Nil(t, err)
+
+	tempFilePath := filepath.Join(tempDir, "temp_file")
+	assert.Nil(t, ioutil.WriteFile(tempFilePath, []byte("original contents"), 0644))
+
+	err = api.DownloadFile(RemoteTestURL, tempFilePath)
+	assert.Equal(t, "connection reset by peer", err.Error())
+
+	contents, err := ioutil.ReadFile(tempFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "original contents", string(contents))
+
+	// The temporary file should have been cleaned up too.
+	files, err := ioutil.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestDownloadRequestCancelled(t *testing.T) {
+	var api *BlobStoreClient = testClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	httpMock := func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+		return nil, request.Context().Err()
+	}
+
+	api.apiClient.(*BlobStoreApiClient).http = &TestDrivenHttpClient{[]HttpMockedMethod{httpMock}}
+	tempDir, err := ioutil.TempDir("", "")
+	defer os.RemoveAll(tempDir)
+
+	assert.Nil(t, err)
+
+	tempFilePath := filepath.Join(tempDir, "temp_file")
+	err = api.DownloadFileContext(ctx, RemoteTestURL, tempFilePath)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = os.Stat(tempFilePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestStatRequest(t *testing.T) {
 	var api *BlobStoreClient = testClient()
 
@@ -232,8 +346,7 @@ func TestStatRequest(t *testing.T) {
 
 		assert.Equal(t, RemoteTestStatHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -243,6 +356,9 @@ func TestStatRequest(t *testing.T) {
 		response.Header = make(map[string][]string)
 		response.Header.Set("Content-Type", RemoteTestFileManualMimeType)
 		response.Header.Set("Content-Length", "1024")
+		response.Header.Set("ETag", "\"abc\"")
+		response.Header.Set("Last-Modified", "Wed, 01 Jan 2020 10:00:00 GMT")
+		response.Header.Set("X-BlobStore-Meta-Owner", "someone")
 
 		return &response, nil
 	}
@@ -257,6 +373,9 @@ func TestStatRequest(t *testing.T) {
 	assert.Equal(t, RemoteTestFileManualMimeType, fileStat.MimeType)
 	assert.Equal(t, 1024, fileStat.SizeBytes)
 	assert.Equal(t, true, fileStat.Exists)
+	assert.Equal(t, "\"abc\"", fileStat.ETag)
+	assert.Equal(t, time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), fileStat.LastModified.UTC())
+	assert.Equal(t, map[string]string{"Owner": "someone"}, fileStat.Metadata)
 }
 
 // Append functions are a bit more difficult to test, because they need to mock
@@ -271,8 +390,7 @@ func TestAppendStringRequest(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		file, err := os.Open(LocalTestFilePath)
 		assert.Nil(t, err)
@@ -297,8 +415,7 @@ func TestAppendStringRequest(t *testing.T) {
 		assert.Equal(t, RemoteTestUploadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
 		assert.Equal(t, RemoteTestFileManualMimeType, request.Header.Get("Content-Type"))
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		body, err := ioutil.ReadAll(request.Body)
 		assert.Nil(t, err)
@@ -342,8 +459,7 @@ func TestAppendFileRequest(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		file, err := os.Open(LocalTestFilePath)
 		assert.Nil(t, err)
@@ -368,8 +484,7 @@ func TestAppendFileRequest(t *testing.T) {
 		assert.Equal(t, RemoteTestUploadHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
 		assert.Equal(t, RemoteTestFileManualMimeType, request.Header.Get("Content-Type"))
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		body, err := ioutil.ReadAll(request.Body)
 		assert.Nil(t, err)
@@ -407,8 +522,7 @@ func TestListRequest(t *testing.T) {
 
 		assert.Equal(t, RemoteTestListDirHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestListDirUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		files := []string{"file-1", "file-2", "file-3"}
 		filesBytes, err := json.Marshal(files)
@@ -436,8 +550,7 @@ func TestDeleteRequest(t *testing.T) {
 
 		assert.Equal(t, RemoteTestDeleteHttpMethod, request.Method)
 		assert.Equal(t, RemoteTestFileUrl, request.URL.String())
-		assert.Equal(t, RemoteTestReadSecret, request.Header.Get("X-BlobStore-Read-Acl"))
-		assert.Equal(t, RemoteTestWriteSecret, request.Header.Get("X-BlobStore-Write-Acl"))
+		assertAclHeaders(t, request)
 
 		response := http.Response{
 			StatusCode: 200,
@@ -0,0 +1,26 @@
+package blob
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+func (b *BlobStoreClient) DeleteFiles(files []*url.URL) (*TransferSummary, error) {
+	return b.DeleteFilesContext(context.Background(), files)
+}
+
+// Deletes several files at once. Files that are already gone count as
+// deleted.
+func (b *BlobStoreClient) DeleteFilesContext(ctx context.Context, files []*url.URL) (*TransferSummary, error) {
+	jobs := make([]TransferJob, 0, len(files))
+	for _, file := range files {
+		jobs = append(jobs, TransferJob{
+			Kind:   TransferDelete,
+			Source: file,
+			Path:   strings.TrimLeft(file.Path, "/"),
+		})
+	}
+
+	return b.newTransferManager().RunContext(ctx, jobs)
+}
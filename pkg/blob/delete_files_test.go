@@ -0,0 +1,38 @@
+package blob
+
+import (
+	"net/url"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func TestDeleteFiles(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	files := []*url.URL{}
+	for _, key := range []string{"prefix/a.txt", "prefix/b.txt", "prefix/c/d.txt", "prefix/broken.txt"} {
+		server.Put(key, []byte(key), "")
+		files = append(files, &url.URL{Scheme: BlobStoreUrlScheme, Path: "/" + key})
+	}
+	files = append(files, &url.URL{Scheme: BlobStoreUrlScheme, Path: "/prefix/gone.txt"})
+	server.Fail("prefix/broken.txt", 500)
+
+	summary, err := api.DeleteFiles(files)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"prefix/a.txt", "prefix/b.txt", "prefix/c/d.txt", "prefix/gone.txt"}, summary.Transferred)
+	assert.Equal(t, 1, len(summary.Failed))
+	assert.Equal(t, "prefix/broken.txt", summary.Failed[0].Path)
+
+	assert.Equal(t, 1, len(server.Keys()))
+	_, exists := server.Get("prefix/broken.txt")
+	assert.True(t, exists)
+}
@@ -0,0 +1,170 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func (b *BlobStoreClient) UploadDirectory(dst *url.URL, source string, force bool) (*TransferSummary, error) {
+	return b.UploadDirectoryContext(context.Background(), dst, source, force)
+}
+
+// Uploads every regular file below source, keeping its path relative to
+// source beneath the destination prefix. Failures of individual files are
+// collected in the summary rather than stopping the whole upload.
+func (b *BlobStoreClient) UploadDirectoryContext(ctx context.Context, dst *url.URL, source string, force bool) (*TransferSummary, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, errors.New("Recursive upload source must be a directory")
+	}
+
+	summary := NewTransferSummary()
+	jobs := []TransferJob{}
+
+	err = filepath.Walk(source, func(localPath string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{localPath, err})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			summary.Skipped = append(summary.Skipped, localPath)
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(source, localPath)
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{localPath, err})
+			return nil
+		}
+
+		jobs = append(jobs, TransferJob{
+			Kind:   TransferUpload,
+			Source: &url.URL{Path: localPath},
+			Destination: &url.URL{
+				Scheme: BlobStoreUrlScheme,
+				Path:   path.Join(dst.Path, filepath.ToSlash(relativePath)),
+			},
+			Force:       force,
+			ContentType: mime.TypeByExtension(filepath.Ext(localPath)),
+		})
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	transferred, err := b.newTransferManager().RunContext(ctx, jobs)
+	summary.merge(transferred)
+
+	return summary, err
+}
+
+func (b *BlobStoreClient) DownloadPrefix(src *url.URL, dest string, force bool) (*TransferSummary, error) {
+	return b.DownloadPrefixContext(context.Background(), src, dest, force)
+}
+
+// Downloads everything below a prefix into dest, recreating the structure of
+// the blobstore as local directories.
+func (b *BlobStoreClient) DownloadPrefixContext(ctx context.Context, src *url.URL, dest string, force bool) (*TransferSummary, error) {
+	prefix := strings.TrimLeft(src.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	keys, err := b.ListPrefixContext(ctx, src.Path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := NewTransferSummary()
+	jobs := []TransferJob{}
+
+	for _, key := range keys {
+		key = strings.TrimLeft(key, "/")
+		if !strings.HasPrefix(key, prefix) {
+			summary.Failed = append(summary.Failed, TransferError{key, fmt.Errorf("Listing returned a path outside of %s", src.Path)})
+			continue
+		}
+
+		relativePath := key[len(prefix):]
+		if relativePath == "" {
+			continue
+		}
+
+		localPath, err := localPathWithin(dest, relativePath)
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{key, err})
+			continue
+		}
+
+		// Directories come back from listings with a trailing slash; they
+		// have no contents of their own, but may be empty on the blobstore.
+		if strings.HasSuffix(key, "/") {
+			if err := os.MkdirAll(localPath, 0755); err != nil {
+				summary.Failed = append(summary.Failed, TransferError{key, err})
+			}
+			continue
+		}
+
+		jobs = append(jobs, TransferJob{
+			Kind: TransferDownload,
+			Source: &url.URL{
+				Scheme: BlobStoreUrlScheme,
+				Path:   key,
+			},
+			Destination: &url.URL{Path: localPath},
+			Force:       force,
+		})
+	}
+
+	transferred, err := b.newTransferManager().RunContext(ctx, jobs)
+	summary.merge(transferred)
+
+	return summary, err
+}
+
+// Resolves a slash separated path from the blobstore beneath root, refusing
+// anything that would end up outside of it.
+func localPathWithin(root string, relativePath string) (string, error) {
+	for _, component := range strings.Split(relativePath, "/") {
+		if component == ".." {
+			return "", fmt.Errorf("Refusing to write %s outside of %s", relativePath, root)
+		}
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(relativePath))
+
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Refusing to write %s outside of %s", relativePath, root)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,157 @@
+package blob
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func writeTestTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+
+	for name, contents := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		assert.Nil(t, ioutil.WriteFile(fullPath, []byte(contents), 0644))
+	}
+
+	return root
+}
+
+func TestUploadDirectory(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"index.html":         "<html></html>",
+		"static/app.js":      "console.log(1);",
+		"static/css/app.css": "body {}",
+		"existing.txt":       "new contents",
+		"broken.txt":         "will fail",
+	})
+	defer os.RemoveAll(root)
+
+	server.Put("prefix/existing.txt", []byte("old contents"), "text/plain")
+	server.Fail("prefix/broken.txt", 500)
+
+	dst, _ := url.Parse("blob:/prefix/")
+	summary, err := api.UploadDirectory(dst, root, false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 3, len(summary.Transferred))
+	assert.Equal(t, []string{filepath.Join(root, "existing.txt")}, summary.Skipped)
+	assert.Equal(t, 1, len(summary.Failed))
+	assert.Equal(t, filepath.Join(root, "broken.txt"), summary.Failed[0].Path)
+	assert.Equal(t, "Failed to transfer 1 of 5 files", summary.Err().Error())
+
+	assert.Equal(t, "<html></html>", storedContents(server, "prefix/index.html"))
+	assert.Equal(t, "text/html; charset=utf-8", storedContentType(server, "prefix/index.html"))
+	assert.Equal(t, "body {}", storedContents(server, "prefix/static/css/app.css"))
+	assert.Equal(t, "text/css; charset=utf-8", storedContentType(server, "prefix/static/css/app.css"))
+	assert.Equal(t, "old contents", storedContents(server, "prefix/existing.txt"))
+}
+
+func TestUploadDirectoryForce(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"existing.txt": "new contents",
+	})
+	defer os.RemoveAll(root)
+
+	server.Put("prefix/existing.txt", []byte("old contents"), "text/plain")
+
+	dst, _ := url.Parse("blob:/prefix")
+	summary, err := api.UploadDirectory(dst, root, true)
+	assert.Nil(t, err)
+	assert.Nil(t, summary.Err())
+
+	assert.Equal(t, "new contents", storedContents(server, "prefix/existing.txt"))
+}
+
+func TestUploadDirectoryNotADirectory(t *testing.T) {
+	api := testClient()
+
+	dst, _ := url.Parse("blob:/prefix")
+	_, err := api.UploadDirectory(dst, LocalTestFilePath, false)
+	assert.Equal(t, "Recursive upload source must be a directory", err.Error())
+}
+
+func TestDownloadPrefix(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("prefix/index.html", []byte("<html></html>"), "text/html")
+	server.Put("prefix/static/app.js", []byte("console.log(1);"), "text/javascript")
+	server.Put("prefix/empty/", []byte(""), "")
+	server.Put("prefix/existing.txt", []byte("new contents"), "text/plain")
+	server.Put("prefix/../escape.txt", []byte("escaped"), "text/plain")
+	server.Put("prefix/broken.txt", []byte(""), "text/plain")
+	server.Put("other/ignored.txt", []byte("ignored"), "text/plain")
+	server.Fail("prefix/broken.txt", 500)
+
+	root := writeTestTree(t, map[string]string{
+		"existing.txt": "old contents",
+	})
+	defer os.RemoveAll(root)
+
+	src, _ := url.Parse("blob:/prefix")
+	summary, err := api.DownloadPrefix(src, root, false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"prefix/index.html", "prefix/static/app.js"}, summary.Transferred)
+	assert.Equal(t, []string{"prefix/existing.txt"}, summary.Skipped)
+	assert.Equal(t, 2, len(summary.Failed))
+	assert.Equal(t, "prefix/../escape.txt", summary.Failed[0].Path)
+	assert.Equal(t, "prefix/broken.txt", summary.Failed[1].Path)
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "static", "app.js"))
+	assert.Nil(t, err)
+	assert.Equal(t, "console.log(1);", string(contents))
+
+	contents, err = ioutil.ReadFile(filepath.Join(root, "existing.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "old contents", string(contents))
+
+	info, err := os.Stat(filepath.Join(root, "empty"))
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = os.Stat(filepath.Join(filepath.Dir(root), "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalPathWithin(t *testing.T) {
+	root := filepath.Join("tmp", "root")
+
+	cases := []struct {
+		RelativePath string
+		Expected     string
+		Fails        bool
+	}{
+		{"file.txt", filepath.Join(root, "file.txt"), false},
+		{"nested/file.txt", filepath.Join(root, "nested", "file.txt"), false},
+		{"nested/./file.txt", filepath.Join(root, "nested", "file.txt"), false},
+		{"../file.txt", "", true},
+		{"nested/../../file.txt", "", true},
+		{"nested/..", "", true},
+	}
+
+	for _, ti := range cases {
+		localPath, err := localPathWithin(root, ti.RelativePath)
+		assert.Equal(t, ti.Fails, err != nil, ti.RelativePath)
+		assert.Equal(t, ti.Expected, localPath)
+	}
+}
@@ -0,0 +1,85 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+const HttpResponseRequestIdHeader = "X-Request-Id"
+
+// Prefix of response headers carrying metadata about a file, in the form
+// http.Header stores them.
+const HttpResponseMetadataHeaderPrefix = "X-Blobstore-Meta-"
+
+var (
+	ErrNotFound     = errors.New("Blobstore object not found")
+	ErrUnauthorized = errors.New("Blobstore request unauthorized")
+	ErrForbidden    = errors.New("Blobstore request forbidden")
+	ErrConflict     = errors.New("Blobstore request conflicted with existing object")
+
+	ErrRangeNotSatisfiable = errors.New("Blobstore object does not contain requested range")
+)
+
+type BlobStoreHttpError struct {
+	Operation  string
+	Path       string
+	StatusCode int
+	Body       string
+	RequestId  string
+
+	// Distinguishes a response without a body from one with an empty body,
+	// so the error message is the same as it has always been.
+	hasBody bool
+}
+
+func NewBlobStoreHttpError(operation string, path string, response *http.Response) error {
+	rv := BlobStoreHttpError{
+		Operation:  operation,
+		Path:       path,
+		StatusCode: response.StatusCode,
+		RequestId:  response.Header.Get(HttpResponseRequestIdHeader),
+	}
+
+	if response.Body == nil {
+		return &rv
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	rv.Body = string(body)
+	rv.hasBody = true
+
+	return &rv
+}
+
+func (e *BlobStoreHttpError) Error() string {
+	if !e.hasBody {
+		return fmt.Sprintf("Blobstore %s Failed (%d)", e.Operation, e.StatusCode)
+	}
+
+	return fmt.Sprintf("Blobstore %s Failed (%d): %s", e.Operation, e.StatusCode, e.Body)
+}
+
+// Unwrap exposes the sentinel error matching the response's status code, so
+// callers can check for categories of failure with errors.Is.
+func (e *BlobStoreHttpError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusRequestedRangeNotSatisfiable:
+		return ErrRangeNotSatisfiable
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBlobStoreHttpError(t *testing.T) {
+	response := http.Response{
+		StatusCode: 404,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("{\"code\":\"NotFound\",\"message\":\"File not found\"}")),
+	}
+	response.Header.Set("X-Request-Id", "abc123")
+
+	err := NewBlobStoreHttpError("Download", RemoteTestFilename, &response)
+	assert.Equal(t, "Blobstore Download Failed (404): {\"code\":\"NotFound\",\"message\":\"File not found\"}", err.Error())
+
+	var httpError *BlobStoreHttpError
+	assert.True(t, errors.As(err, &httpError))
+	assert.Equal(t, "Download", httpError.Operation)
+	assert.Equal(t, RemoteTestFilename, httpError.Path)
+	assert.Equal(t, 404, httpError.StatusCode)
+	assert.Equal(t, "{\"code\":\"NotFound\",\"message\":\"File not found\"}", httpError.Body)
+	assert.Equal(t, "abc123", httpError.RequestId)
+}
+
+func TestNewBlobStoreHttpErrorNoBody(t *testing.T) {
+	response := http.Response{
+		StatusCode: 403,
+	}
+
+	err := NewBlobStoreHttpError("Stat", RemoteTestFilename, &response)
+	assert.Equal(t, "Blobstore Stat Failed (403)", err.Error())
+}
+
+func TestBlobStoreHttpErrorSentinels(t *testing.T) {
+	cases := []struct {
+		StatusCode int
+		Sentinel   error
+	}{
+		{404, ErrNotFound},
+		{401, ErrUnauthorized},
+		{403, ErrForbidden},
+		{409, ErrConflict},
+	}
+
+	sentinels := []error{ErrNotFound, ErrUnauthorized, ErrForbidden, ErrConflict}
+
+	for _, ti := range cases {
+		err := &BlobStoreHttpError{StatusCode: ti.StatusCode}
+		for _, sentinel := range sentinels {
+			assert.Equal(t, sentinel == ti.Sentinel, errors.Is(err, sentinel))
+		}
+	}
+
+	err := &BlobStoreHttpError{StatusCode: 500}
+	for _, sentinel := range sentinels {
+		assert.False(t, errors.Is(err, sentinel))
+	}
+}
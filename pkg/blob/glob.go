@@ -0,0 +1,171 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Returned when a glob pattern doesn't match any files. It counts as the
+// file not being found.
+type NoGlobMatchesError struct {
+	Pattern string
+}
+
+func (e *NoGlobMatchesError) Error() string {
+	return fmt.Sprintf("No files match %s", e.Pattern)
+}
+
+func (e *NoGlobMatchesError) Unwrap() error {
+	return ErrNotFound
+}
+
+func HasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// Finds the longest prefix of the pattern without any glob characters in it,
+// which is the only part of the blobstore that needs to be listed to find
+// its matches. The listing only needs to be recursive if the pattern can
+// match below the prefix's immediate children.
+func GlobPrefix(pattern string) (string, bool) {
+	components := strings.Split(strings.TrimLeft(pattern, "/"), "/")
+
+	literal := 0
+	for literal < len(components) && !HasGlobMeta(components[literal]) {
+		literal++
+	}
+
+	if literal == len(components) {
+		return strings.Join(components, "/"), false
+	}
+
+	prefix := ""
+	if literal > 0 {
+		prefix = strings.Join(components[:literal], "/") + "/"
+	}
+
+	recursive := literal < len(components)-1 || strings.Contains(pattern, "**")
+	return prefix, recursive
+}
+
+// Matches a key against a pattern one path component at a time. *, ? and
+// [...] behave as in path.Match, and never match a /. A component that is
+// only ** matches any number of components, including none.
+func MatchGlob(pattern string, key string) (bool, error) {
+	patternComponents := strings.Split(strings.TrimLeft(pattern, "/"), "/")
+	keyComponents := strings.Split(strings.TrimLeft(key, "/"), "/")
+
+	matched, err := matchGlobComponents(patternComponents, keyComponents)
+	if err != nil {
+		return false, fmt.Errorf("Invalid glob pattern %s", pattern)
+	}
+
+	return matched, nil
+}
+
+func matchGlobComponents(pattern []string, key []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(key) == 0, nil
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(key); i++ {
+			matched, err := matchGlobComponents(pattern[1:], key[i:])
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+
+	if len(key) == 0 {
+		return false, nil
+	}
+
+	matched, err := path.Match(pattern[0], key[0])
+	if err != nil || !matched {
+		return false, err
+	}
+
+	return matchGlobComponents(pattern[1:], key[1:])
+}
+
+func (b *BlobStoreClient) Glob(pattern string) ([]string, error) {
+	return b.GlobContext(context.Background(), pattern)
+}
+
+// Lists the files matching a glob pattern, in the order the blobstore lists
+// them. Directories are never matched.
+func (b *BlobStoreClient) GlobContext(ctx context.Context, pattern string) ([]string, error) {
+	prefix, recursive := GlobPrefix(pattern)
+
+	keys, err := b.ListPrefixContext(ctx, prefix, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	return MatchingKeys(pattern, keys)
+}
+
+// Keeps the files from a listing that match a pattern. Directories, which
+// are listed with a trailing /, are never matched.
+func MatchingKeys(pattern string, keys []string) ([]string, error) {
+	matches, err := matchingListedPaths(pattern, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := make([]string, 0, len(matches))
+	for _, i := range matches {
+		rv = append(rv, keys[i])
+	}
+
+	return rv, nil
+}
+
+// Keeps the files from a listing with stats that match a pattern, the same
+// way MatchingKeys does.
+func MatchingFileStats(pattern string, stats []BlobFileStat) ([]BlobFileStat, error) {
+	paths := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		paths = append(paths, strings.TrimPrefix(stat.Path, "/")+stat.Name)
+	}
+
+	matches, err := matchingListedPaths(pattern, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := make([]BlobFileStat, 0, len(matches))
+	for _, i := range matches {
+		rv = append(rv, stats[i])
+	}
+
+	return rv, nil
+}
+
+func matchingListedPaths(pattern string, paths []string) ([]int, error) {
+	matches := []int{}
+	for i, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			continue
+		}
+
+		matched, err := MatchGlob(pattern, p)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, &NoGlobMatchesError{pattern}
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,113 @@
+package blob
+
+import (
+	"errors"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func TestGlobPrefix(t *testing.T) {
+	cases := []struct {
+		Pattern   string
+		Prefix    string
+		Recursive bool
+	}{
+		{"/logs/2026-09-*.gz", "logs/", false},
+		{"*.csv", "", false},
+		{"/reports/*/summary.csv", "reports/", true},
+		{"/reports/**", "reports/", true},
+		{"/a/b/c?/d", "a/b/", true},
+		{"/a/[bc]", "a/", false},
+	}
+
+	for _, ti := range cases {
+		prefix, recursive := GlobPrefix(ti.Pattern)
+		assert.Equal(t, ti.Prefix, prefix, ti.Pattern)
+		assert.Equal(t, ti.Recursive, recursive, ti.Pattern)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		Pattern string
+		Key     string
+		Matches bool
+	}{
+		{"/logs/2026-09-*.gz", "logs/2026-09-01.gz", true},
+		{"/logs/2026-09-*.gz", "logs/2026-10-01.gz", false},
+		{"/logs/*.gz", "logs/nested/a.gz", false},
+		{"/logs/?.gz", "logs/a.gz", true},
+		{"/logs/?.gz", "logs/ab.gz", false},
+		{"/logs/[ab].gz", "logs/b.gz", true},
+		{"/logs/[^ab].gz", "logs/b.gz", false},
+		{"/logs/**", "logs/a/b/c.gz", true},
+		{"/logs/**/*.gz", "logs/a.gz", true},
+		{"/logs/**/*.gz", "logs/a/b/c.gz", true},
+		{"/logs/**/*.gz", "logs/a/b/c.txt", false},
+		{"/**/c.gz", "logs/a/b/c.gz", true},
+		{"/logs/\\*.gz", "logs/*.gz", true},
+		{"/logs/\\*.gz", "logs/a.gz", false},
+	}
+
+	for _, ti := range cases {
+		matched, err := MatchGlob(ti.Pattern, ti.Key)
+		assert.Nil(t, err)
+		assert.Equal(t, ti.Matches, matched, ti.Pattern+" "+ti.Key)
+	}
+
+	_, err := MatchGlob("/logs/[a", "logs/a")
+	assert.Equal(t, "Invalid glob pattern /logs/[a", err.Error())
+}
+
+func TestGlob(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("logs/2026-09-01.gz", []byte("a"), "")
+	server.Put("logs/2026-09-02.gz", []byte("b"), "")
+	server.Put("logs/2026-10-01.gz", []byte("c"), "")
+	server.Put("logs/2026-09-xx/nested.gz", []byte("d"), "")
+	server.Put("other/2026-09-01.gz", []byte("e"), "")
+
+	matches, err := api.Glob("/logs/2026-09-*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2026-09-01.gz", "logs/2026-09-02.gz"}, matches)
+
+	matches, err = api.Glob("/**/2026-09-01.gz")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2026-09-01.gz", "other/2026-09-01.gz"}, matches)
+
+	matches, err = api.Glob("/logs/*/*.gz")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2026-09-xx/nested.gz"}, matches)
+
+	_, err = api.Glob("/logs/2025-*")
+	assert.Equal(t, "No files match /logs/2025-*", err.Error())
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestMatchingFileStats(t *testing.T) {
+	stats := []BlobFileStat{
+		{Path: "logs/", Name: "2026-09-01.gz"},
+		{Path: "logs/", Name: "2026-09-xx/"},
+		{Path: "logs/", Name: "2026-10-01.gz"},
+	}
+
+	matches, err := MatchingFileStats("/logs/2026-*", stats)
+	assert.Nil(t, err)
+	assert.Equal(t, []BlobFileStat{stats[0], stats[2]}, matches)
+
+	keys, err := MatchingKeys("/logs/2026-*", []string{"logs/2026-09-01.gz", "logs/2026-09-xx/", "logs/2026-10-01.gz"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/2026-09-01.gz", "logs/2026-10-01.gz"}, keys)
+
+	_, err = MatchingFileStats("/logs/2025-*", stats)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,155 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of files stat'd at once when the server's listing doesn't include
+// any details about them.
+const DefaultListStatConcurrency int = 8
+
+// The shape of each entry in a listing requested with stat=true. Servers that
+// don't support it return bare paths instead.
+type listedBlobFile struct {
+	Path         string    `json:"path"`
+	Size         int       `json:"size"`
+	MimeType     string    `json:"mime_type"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Splits a key from a listing the same way NewBlobFileStatFromResponse
+// splits a request path. Directories keep their trailing slash in Name.
+func newListedBlobFileStat(key string) BlobFileStat {
+	key = strings.TrimLeft(key, "/")
+	slash := strings.LastIndex(strings.TrimSuffix(key, "/"), "/")
+
+	return BlobFileStat{
+		Path:   key[:slash+1],
+		Name:   key[slash+1:],
+		Exists: true,
+	}
+}
+
+func (b *BlobStoreApiClient) ListPrefixStat(prefix string, recursive bool) ([]BlobFileStat, error) {
+	return b.ListPrefixStatContext(context.Background(), prefix, recursive)
+}
+
+// Lists a prefix along with the size, type and modification time of each
+// file in it. If the server only returns paths, each file is stat'd
+// separately.
+func (b *BlobStoreApiClient) ListPrefixStatContext(ctx context.Context, prefix string, recursive bool) ([]BlobFileStat, error) {
+	prefix = strings.TrimLeft(prefix, "/")
+
+	query := "stat=true"
+	if recursive {
+		query += "&recursive=true"
+	}
+
+	response, err := b.doWithRetries(ctx, true, func() (*http.Request, error) {
+		return b.newAuthorizedListRequest(ctx, prefix, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, NewBlobStoreHttpError("List", prefix, response)
+	}
+
+	entries := []json.RawMessage{}
+	if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	stats := make([]BlobFileStat, len(entries))
+	unstated := []int{}
+
+	for i, entry := range entries {
+		var key string
+		if err := json.Unmarshal(entry, &key); err == nil {
+			stats[i] = newListedBlobFileStat(key)
+			if !strings.HasSuffix(key, "/") {
+				unstated = append(unstated, i)
+			}
+			continue
+		}
+
+		var listed listedBlobFile
+		if err := json.Unmarshal(entry, &listed); err != nil {
+			return nil, err
+		}
+
+		stats[i] = newListedBlobFileStat(listed.Path)
+		stats[i].SizeBytes = listed.Size
+		stats[i].MimeType = listed.MimeType
+		stats[i].ETag = listed.ETag
+		stats[i].LastModified = listed.LastModified
+	}
+
+	if err := b.statListedFiles(ctx, stats, unstated); err != nil {
+		return nil, err
+	}
+
+	rv := make([]BlobFileStat, 0, len(stats))
+	for _, stat := range stats {
+		if stat.Exists {
+			rv = append(rv, stat)
+		}
+	}
+
+	return rv, nil
+}
+
+// Fills in the stats at the given indexes, a few at a time. Anything deleted
+// since it was listed is marked as not existing.
+func (b *BlobStoreApiClient) statListedFiles(ctx context.Context, stats []BlobFileStat, indexes []int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+
+	for w := 0; w < DefaultListStatConcurrency && w < len(indexes); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				listed := stats[i]
+				stat, err := b.GetStatContext(ctx, listed.Path+listed.Name)
+				if err != nil {
+					lock.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					lock.Unlock()
+					continue
+				}
+
+				stat.Path = listed.Path
+				stat.Name = listed.Name
+				stats[i] = *stat
+			}
+		}()
+	}
+
+	for _, i := range indexes {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,67 @@
+package blob
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func TestListPrefixStatFallsBackToStat(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{PlainListings: true})
+	defer server.Close()
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	server.PutObject("prefix/a.txt", blobtest.Object{Contents: []byte("aaa"), ContentType: "text/plain", ETag: "\"a\"", ModTime: modTime})
+	server.PutObject("prefix/nested/b.json", blobtest.Object{Contents: []byte("{}"), ContentType: "application/json", ETag: "\"b\"", ModTime: modTime})
+
+	stats, err := api.ListPrefixStat("/prefix", false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []BlobFileStat{
+		{Path: "prefix/", Name: "a.txt", MimeType: "text/plain", SizeBytes: 3, Exists: true, ETag: "\"a\"", LastModified: modTime},
+		{Path: "prefix/", Name: "nested/", Exists: true},
+	}, stats)
+
+	stats, err = api.ListPrefixStat("prefix", true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stats))
+	assert.Equal(t, "prefix/nested/", stats[1].Path)
+	assert.Equal(t, "b.json", stats[1].Name)
+	assert.Equal(t, 2, stats[1].SizeBytes)
+}
+
+func TestListPrefixStatFromListing(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	server.PutObject("prefix/a.txt", blobtest.Object{Contents: []byte("aaa"), ContentType: "text/plain", ETag: "\"a\"", ModTime: modTime})
+
+	// Any stat request would fail, so the details must come from the listing.
+	server.Fail("prefix/a.txt", 500)
+
+	stats, err := api.ListPrefixStat("prefix", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []BlobFileStat{
+		{Path: "prefix/", Name: "a.txt", MimeType: "text/plain", SizeBytes: 3, Exists: true, ETag: "\"a\"", LastModified: modTime},
+	}, stats)
+}
+
+func TestListPrefixStatFails(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{PlainListings: true})
+	defer server.Close()
+
+	server.Put("prefix/a.txt", []byte("a"), "")
+	server.Put("prefix/b.txt", []byte("b"), "")
+	server.Fail("prefix/b.txt", 403)
+
+	_, err := api.ListPrefixStat("prefix", true)
+	assert.Equal(t, "Blobstore Stat Failed (403): ", err.Error())
+}
@@ -0,0 +1,285 @@
+package blob
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func sameBlobPath(a, b *url.URL) bool {
+	return strings.Trim(a.Path, "/") == strings.Trim(b.Path, "/")
+}
+
+// Streams one blob straight into another, without going through the local
+// disk, and keeps its MIME type.
+func (b *BlobStoreClient) copyBlobContext(ctx context.Context, src *url.URL, dst *url.URL) error {
+	file, err := b.apiClient.GetFileContext(ctx, src.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Contents.Close()
+
+	// The download already reports progress for the same bytes.
+	return b.apiClient.UploadStreamContext(withoutProgress(ctx), dst.Path, bufio.NewReader(file.Contents), file.Info.MimeType)
+}
+
+func (b *BlobStoreClient) CopyPrefix(src *url.URL, dst *url.URL, force bool) (*TransferSummary, error) {
+	return b.CopyPrefixContext(context.Background(), src, dst, force)
+}
+
+// Copies everything below one prefix of the blobstore to another.
+func (b *BlobStoreClient) CopyPrefixContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) (*TransferSummary, error) {
+	if src.Scheme != BlobStoreUrlScheme || dst.Scheme != BlobStoreUrlScheme {
+		return nil, errors.New("Can only copy prefixes between two blob:/ paths")
+	}
+
+	return b.transferTree(ctx, src, dst, force, TransferCopy)
+}
+
+func (b *BlobStoreClient) Move(src *url.URL, dst *url.URL, force bool) error {
+	return b.MoveContext(context.Background(), src, dst, force)
+}
+
+// Copies the file, and only removes the source once the destination is the
+// same size as it.
+func (b *BlobStoreClient) MoveContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) error {
+	if src.Scheme != BlobStoreUrlScheme && dst.Scheme != BlobStoreUrlScheme {
+		return errors.New("Must provide at least one blob:/ path to move to or from")
+	}
+
+	srcSize, err := b.sizeOf(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	if err := b.CopyContext(ctx, src, dst, force); err != nil {
+		return err
+	}
+
+	dstSize, err := b.sizeOf(ctx, dst)
+	if err != nil {
+		return err
+	}
+
+	if srcSize != dstSize {
+		return fmt.Errorf("Destination is %d bytes, but source is %d bytes; leaving source in place", dstSize, srcSize)
+	}
+
+	if src.Scheme == BlobStoreUrlScheme {
+		return b.DeleteFileContext(ctx, src)
+	}
+
+	return os.Remove(src.Path)
+}
+
+func (b *BlobStoreClient) sizeOf(ctx context.Context, url_ *url.URL) (int64, error) {
+	if url_.Scheme != BlobStoreUrlScheme {
+		info, err := os.Stat(url_.Path)
+		if err != nil {
+			return 0, err
+		}
+
+		if info.IsDir() {
+			return 0, fmt.Errorf("%s is a directory", url_.Path)
+		}
+
+		return info.Size(), nil
+	}
+
+	stat, err := b.StatFileContext(ctx, url_)
+	if err != nil {
+		return 0, err
+	}
+
+	if !stat.Exists {
+		return 0, &BlobStoreHttpError{
+			Operation:  "Stat",
+			Path:       url_.Path,
+			StatusCode: http.StatusNotFound,
+		}
+	}
+
+	return int64(stat.SizeBytes), nil
+}
+
+func (b *BlobStoreClient) MoveRecursive(src *url.URL, dst *url.URL, force bool) (*TransferSummary, error) {
+	return b.MoveRecursiveContext(context.Background(), src, dst, force)
+}
+
+// Moves every file below src, in any direction between the local machine
+// and the blobstore. Local directories emptied by the move are removed, as
+// long as every file was moved.
+func (b *BlobStoreClient) MoveRecursiveContext(ctx context.Context, src *url.URL, dst *url.URL, force bool) (*TransferSummary, error) {
+	if src.Scheme != BlobStoreUrlScheme && dst.Scheme != BlobStoreUrlScheme {
+		return nil, errors.New("Must provide at least one blob:/ path to move to or from")
+	}
+
+	summary, err := b.transferTree(ctx, src, dst, force, TransferMove)
+
+	if src.Scheme != BlobStoreUrlScheme && err == nil && summary != nil && len(summary.Failed) == 0 {
+		removeEmptiedDirectories(src.Path, summary.Transferred)
+	}
+
+	return summary, err
+}
+
+// Runs a job of the given kind for every file below src, with its matching
+// path below dst. Destinations that already exist are skipped unless force
+// is set.
+func (b *BlobStoreClient) transferTree(ctx context.Context, src *url.URL, dst *url.URL, force bool, kind TransferJobKind) (*TransferSummary, error) {
+	summary := NewTransferSummary()
+
+	relativePaths, err := b.listTree(ctx, src, summary)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []TransferJob{}
+	for _, relativePath := range relativePaths {
+		srcFile, err := joinTree(src, relativePath)
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{relativePath, err})
+			continue
+		}
+
+		dstFile, err := joinTree(dst, relativePath)
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{srcFile.Path, err})
+			continue
+		}
+
+		jobs = append(jobs, TransferJob{
+			Kind:        kind,
+			Source:      srcFile,
+			Destination: dstFile,
+			Force:       force,
+		})
+	}
+
+	transferred, err := b.newTransferManager().RunContext(ctx, jobs)
+	summary.merge(transferred)
+
+	return summary, err
+}
+
+// Lists the files below a local directory or blob prefix, as slash separated
+// paths relative to it. Anything that can't be transferred is recorded as
+// skipped in the summary.
+func (b *BlobStoreClient) listTree(ctx context.Context, root *url.URL, summary *TransferSummary) ([]string, error) {
+	relativePaths := []string{}
+
+	if root.Scheme == BlobStoreUrlScheme {
+		prefix := strings.TrimLeft(root.Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+
+		keys, err := b.ListPrefixContext(ctx, root.Path, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			key = strings.TrimLeft(key, "/")
+			if !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, "/") {
+				summary.Skipped = append(summary.Skipped, key)
+				continue
+			}
+
+			relativePaths = append(relativePaths, key[len(prefix):])
+		}
+
+		return relativePaths, nil
+	}
+
+	info, err := os.Stat(root.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root.Path)
+	}
+
+	err = filepath.Walk(root.Path, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{localPath, err})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			summary.Skipped = append(summary.Skipped, localPath)
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root.Path, localPath)
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{localPath, err})
+			return nil
+		}
+
+		relativePaths = append(relativePaths, filepath.ToSlash(relativePath))
+		return nil
+	})
+
+	return relativePaths, err
+}
+
+func joinTree(root *url.URL, relativePath string) (*url.URL, error) {
+	if root.Scheme == BlobStoreUrlScheme {
+		return &url.URL{
+			Scheme: BlobStoreUrlScheme,
+			Path:   path.Join(root.Path, relativePath),
+		}, nil
+	}
+
+	localPath, err := localPathWithin(root.Path, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{Path: localPath}, nil
+}
+
+// Removes the directories between root and each of the moved files, deepest
+// first, if nothing is left in them. Directories that didn't hold any of the
+// moved files are left alone, even if they're empty.
+func removeEmptiedDirectories(root string, moved []string) {
+	root = filepath.Clean(root)
+
+	seen := map[string]bool{}
+	directories := []string{}
+	for _, file := range moved {
+		directory := filepath.Dir(file)
+		for !seen[directory] && directoryWithin(root, directory) {
+			seen[directory] = true
+			directories = append(directories, directory)
+			directory = filepath.Dir(directory)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(directories)))
+	for _, directory := range directories {
+		os.Remove(directory)
+	}
+}
+
+func directoryWithin(root string, directory string) bool {
+	rel, err := filepath.Rel(root, directory)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,202 @@
+package blob
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func TestCopyBlobToBlob(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("source.json", []byte("{}"), "application/json")
+
+	src, _ := url.Parse("blob:/source.json")
+	dst, _ := url.Parse("blob:/copied/source.json")
+	assert.Nil(t, api.Copy(src, dst, false))
+
+	assert.Equal(t, "{}", storedContents(server, "copied/source.json"))
+	assert.Equal(t, "application/json", storedContentType(server, "copied/source.json"))
+	assert.Equal(t, "{}", storedContents(server, "source.json"))
+
+	assert.Equal(t, "Destination file already exists on blobstore; use --force to overwrite", api.Copy(src, dst, false).Error())
+	assert.Equal(t, "Source and destination are the same file", api.Copy(src, src, true).Error())
+}
+
+func TestCopyPrefix(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("prefix/a.txt", []byte("a"), "text/plain")
+	server.Put("prefix/nested/b.txt", []byte("b"), "text/plain")
+	server.Put("prefix/existing.txt", []byte("new"), "text/plain")
+	server.Put("other/existing.txt", []byte("old"), "text/plain")
+
+	src, _ := url.Parse("blob:/prefix")
+	dst, _ := url.Parse("blob:/other")
+	summary, err := api.CopyPrefix(src, dst, false)
+	assert.Nil(t, err)
+	assert.Nil(t, summary.Err())
+
+	assert.Equal(t, []string{"/prefix/a.txt", "/prefix/nested/b.txt"}, summary.Transferred)
+	assert.Equal(t, []string{"/prefix/existing.txt"}, summary.Skipped)
+	assert.Equal(t, "b", storedContents(server, "other/nested/b.txt"))
+	assert.Equal(t, "old", storedContents(server, "other/existing.txt"))
+	assert.Equal(t, "a", storedContents(server, "prefix/a.txt"))
+}
+
+func TestMoveBlobToBlob(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("source.txt", []byte("contents"), "text/plain")
+
+	src, _ := url.Parse("blob:/source.txt")
+	dst, _ := url.Parse("blob:/moved.txt")
+	assert.Nil(t, api.Move(src, dst, false))
+
+	_, exists := server.Get("source.txt")
+	assert.False(t, exists)
+	assert.Equal(t, "contents", storedContents(server, "moved.txt"))
+	assert.Equal(t, "text/plain", storedContentType(server, "moved.txt"))
+}
+
+func TestMoveMissingSource(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	src, _ := url.Parse("blob:/missing.txt")
+	dst, _ := url.Parse("blob:/moved.txt")
+	err := api.Move(src, dst, false)
+	assert.Equal(t, "Blobstore Stat Failed (404)", err.Error())
+}
+
+func TestMoveFailedUploadKeepsSource(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"source.txt": "contents",
+	})
+	defer os.RemoveAll(root)
+
+	server.Fail("moved.txt", 500)
+
+	src := &url.URL{Path: filepath.Join(root, "source.txt")}
+	dst, _ := url.Parse("blob:/moved.txt")
+	assert.NotNil(t, api.Move(src, dst, true))
+
+	_, err := os.Stat(src.Path)
+	assert.Nil(t, err)
+}
+
+func TestMoveRecursiveFromLocal(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"a.txt":        "a",
+		"nested/b.txt": "bb",
+		"existing.txt": "new",
+	})
+	defer os.RemoveAll(root)
+
+	server.Put("prefix/existing.txt", []byte("old"), "text/plain")
+
+	dst, _ := url.Parse("blob:/prefix")
+	summary, err := api.MoveRecursive(&url.URL{Path: root}, dst, false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{filepath.Join(root, "a.txt"), filepath.Join(root, "nested", "b.txt")}, summary.Transferred)
+	assert.Equal(t, []string{filepath.Join(root, "existing.txt")}, summary.Skipped)
+	assert.Equal(t, "bb", storedContents(server, "prefix/nested/b.txt"))
+
+	_, err = os.Stat(filepath.Join(root, "nested"))
+	assert.True(t, os.IsNotExist(err))
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "existing.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(contents))
+}
+
+func TestMoveRecursiveToLocal(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("prefix/a.txt", []byte("a"), "text/plain")
+	server.Put("prefix/nested/b.txt", []byte("bb"), "text/plain")
+
+	root, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	src, _ := url.Parse("blob:/prefix")
+	summary, err := api.MoveRecursive(src, &url.URL{Path: root}, false)
+	assert.Nil(t, err)
+	assert.Nil(t, summary.Err())
+
+	assert.Equal(t, 0, len(server.Keys()))
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "nested", "b.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bb", string(contents))
+}
+
+func TestMoveRecursiveOnlyRemovesEmptiedDirectories(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"a/b.txt": "b",
+	})
+	defer os.RemoveAll(root)
+
+	assert.Nil(t, os.Mkdir(filepath.Join(root, "empty"), 0755))
+
+	dst, _ := url.Parse("blob:/prefix")
+	summary, err := api.MoveRecursive(&url.URL{Path: root}, dst, false)
+	assert.Nil(t, err)
+	assert.Nil(t, summary.Err())
+
+	_, err = os.Stat(filepath.Join(root, "a"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(root, "empty"))
+	assert.Nil(t, err)
+}
+
+func TestMoveRecursiveKeepsDirectoriesAfterFailures(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"a/b.txt": "b",
+		"c/d.txt": "d",
+	})
+	defer os.RemoveAll(root)
+
+	server.Fail("prefix/c/d.txt", 500)
+
+	dst, _ := url.Parse("blob:/prefix")
+	summary, err := api.MoveRecursive(&url.URL{Path: root}, dst, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "a", "b.txt")}, summary.Transferred)
+	assert.Equal(t, 1, len(summary.Failed))
+
+	_, err = os.Stat(filepath.Join(root, "a"))
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(root, "c", "d.txt"))
+	assert.Nil(t, err)
+}
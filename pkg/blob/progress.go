@@ -0,0 +1,60 @@
+package blob
+
+import (
+	"context"
+	"io"
+)
+
+// Called as the contents of a file are read or sent, with the number of
+// bytes so far. The total is -1 when it isn't known. If a request is
+// retried, transferred starts again from zero.
+//
+// Bulk operations transfer several files at once, so this may be called
+// from many goroutines at the same time.
+type ProgressFunc func(path string, transferred int64, total int64)
+
+type progressKey struct{}
+
+// Reports the progress of every upload and download made with the context.
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+func withoutProgress(ctx context.Context) context.Context {
+	return context.WithValue(ctx, progressKey{}, ProgressFunc(nil))
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	progress, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return progress
+}
+
+type progressReader struct {
+	reader      io.Reader
+	path        string
+	transferred int64
+	total       int64
+	progress    ProgressFunc
+}
+
+// Counts the bytes read from reader, if the context wants progress reported.
+// Otherwise the reader is returned as it is.
+func newProgressReader(ctx context.Context, path string, reader io.Reader, total int64) io.Reader {
+	progress := progressFromContext(ctx)
+	if progress == nil {
+		return reader
+	}
+
+	progress(path, 0, total)
+	return &progressReader{reader, path, 0, total, progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.progress(r.path, r.transferred, r.total)
+	}
+
+	return n, err
+}
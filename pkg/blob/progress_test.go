@@ -0,0 +1,147 @@
+package blob
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
+)
+
+type recordedProgress struct {
+	lock    sync.Mutex
+	updates map[string][][2]int64
+}
+
+func (r *recordedProgress) record(path string, transferred int64, total int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.updates[path] = append(r.updates[path], [2]int64{transferred, total})
+}
+
+func newRecordedProgressContext() (context.Context, *recordedProgress) {
+	progress := &recordedProgress{updates: map[string][][2]int64{}}
+	return WithProgress(context.Background(), progress.record), progress
+}
+
+func TestProgressReader(t *testing.T) {
+	ctx, progress := newRecordedProgressContext()
+
+	reader := newProgressReader(ctx, "file.txt", strings.NewReader("abcdef"), 6)
+	buffer := make([]byte, 4)
+
+	n, err := reader.Read(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+
+	rest, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "ef", string(rest))
+
+	assert.Equal(t, [][2]int64{{0, 6}, {4, 6}, {6, 6}}, progress.updates["file.txt"])
+}
+
+func TestProgressReaderWithoutProgress(t *testing.T) {
+	reader := strings.NewReader("abc")
+	assert.Equal(t, reader, newProgressReader(context.Background(), "file.txt", reader, 3))
+	assert.Equal(t, reader, newProgressReader(withoutProgress(WithProgress(context.Background(), func(string, int64, int64) {})), "file.txt", reader, 3))
+}
+
+func TestProgressUploadAndDownload(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	localPath := filepath.Join(dir, "file.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("abcdef"), 0644))
+
+	ctx, progress := newRecordedProgressContext()
+	dst, _ := url.Parse("blob:/file.txt")
+	assert.Nil(t, api.CopyContext(ctx, &url.URL{Path: localPath}, dst, false))
+
+	updates := progress.updates["/file.txt"]
+	assert.Equal(t, [2]int64{0, 6}, updates[0])
+	assert.Equal(t, [2]int64{6, 6}, updates[len(updates)-1])
+
+	ctx, progress = newRecordedProgressContext()
+	downloadPath := filepath.Join(dir, "downloaded.txt")
+	assert.Nil(t, api.CopyContext(ctx, dst, &url.URL{Path: downloadPath}, false))
+
+	updates = progress.updates["/file.txt"]
+	assert.Equal(t, [2]int64{0, 6}, updates[0])
+	assert.Equal(t, [2]int64{6, 6}, updates[len(updates)-1])
+
+	ctx, progress = newRecordedProgressContext()
+	assert.Nil(t, api.apiClient.UploadStreamContext(ctx, "/stream.txt", bufio.NewReader(strings.NewReader("abc")), "text/plain"))
+
+	updates = progress.updates["/stream.txt"]
+	assert.Equal(t, [2]int64{0, -1}, updates[0])
+	assert.Equal(t, [2]int64{3, -1}, updates[len(updates)-1])
+}
+
+func TestProgressRangeDownload(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("file.txt", []byte("abcdef"), "text/plain")
+
+	ctx, progress := newRecordedProgressContext()
+	file, err := api.apiClient.GetFileRangeContext(ctx, "/file.txt", 2, 3)
+	assert.Nil(t, err)
+	defer file.Contents.Close()
+
+	contents, err := ioutil.ReadAll(file.Contents)
+	assert.Nil(t, err)
+	assert.Equal(t, "cde", string(contents))
+
+	updates := progress.updates["/file.txt"]
+	assert.Equal(t, [2]int64{0, 3}, updates[0])
+	assert.Equal(t, [2]int64{3, 3}, updates[len(updates)-1])
+}
+
+func TestProgressSignedUpload(t *testing.T) {
+	server := blobtest.NewServer(blobtest.Config{SigningKeys: map[string]string{"key": "secret"}})
+	defer server.Close()
+
+	api := NewBlobStoreApiClient(server.URL, &credential_provider.SigningCredentialProvider{KeyId: "key", Secret: "secret"})
+
+	ctx, progress := newRecordedProgressContext()
+	assert.Nil(t, api.UploadSeekableStreamContext(ctx, "/file.txt", strings.NewReader("abcdef"), "text/plain"))
+	assert.Equal(t, "abcdef", storedContents(server, "file.txt"))
+
+	updates := progress.updates["/file.txt"]
+	assert.Equal(t, [2]int64{6, 6}, updates[len(updates)-1])
+}
+
+func TestProgressCopyBlobToBlobCountsOnce(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	server.Put("source.txt", []byte("abc"), "text/plain")
+
+	ctx, progress := newRecordedProgressContext()
+	src, _ := url.Parse("blob:/source.txt")
+	dst, _ := url.Parse("blob:/copied.txt")
+	assert.Nil(t, api.CopyContext(ctx, src, dst, false))
+
+	assert.Equal(t, 1, len(progress.updates))
+	updates := progress.updates["/source.txt"]
+	assert.Equal(t, [2]int64{3, 3}, updates[len(updates)-1])
+}
@@ -0,0 +1,197 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const (
+	PartialDownloadSuffix      = ".blobpart"
+	PartialDownloadStateSuffix = ".blobpart.json"
+)
+
+var errRemoteFileChanged = errors.New("File on the blobstore changed during download; try again")
+
+// Recorded beside a partial download, so that a later attempt can tell
+// whether the bytes already on disk still belong to the remote file.
+type partialDownloadState struct {
+	Path      string
+	SizeBytes int
+	Validator string
+}
+
+func statValidator(stat *BlobFileStat) string {
+	if stat.ETag != "" {
+		return stat.ETag
+	}
+
+	if !stat.LastModified.IsZero() {
+		return stat.LastModified.UTC().Format(http.TimeFormat)
+	}
+
+	return ""
+}
+
+func readPartialDownloadState(statePath string) (*partialDownloadState, error) {
+	stateBytes, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state partialDownloadState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func writePartialDownloadState(statePath string, state *partialDownloadState) error {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(statePath, stateBytes, 0644)
+}
+
+func removePartialDownload(dest string) {
+	os.Remove(dest + PartialDownloadSuffix)
+	os.Remove(dest + PartialDownloadStateSuffix)
+}
+
+// Works out how much of a previous attempt can be kept. Anything that can't
+// be proven to match the remote file is thrown away, but a previous attempt
+// at a file with no ETag or Last-Modified time can never be proven to match,
+// so that's reported rather than quietly starting over.
+func resumableOffset(dest string, state *partialDownloadState) (int64, error) {
+	if state.Validator == "" {
+		if info, err := os.Stat(dest + PartialDownloadSuffix); err == nil && info.Size() > 0 {
+			return 0, fmt.Errorf("Cannot resume download of %s, because the blobstore reports neither an ETag nor a Last-Modified time for it; remove %s to download it again", state.Path, dest+PartialDownloadSuffix)
+		}
+		return 0, nil
+	}
+
+	existing, err := readPartialDownloadState(dest + PartialDownloadStateSuffix)
+	if err != nil || *existing != *state {
+		return 0, nil
+	}
+
+	info, err := os.Stat(dest + PartialDownloadSuffix)
+	if err != nil || info.Size() > int64(state.SizeBytes) {
+		return 0, nil
+	}
+
+	return info.Size(), nil
+}
+
+func (b *BlobStoreClient) DownloadFileResumable(url_ *url.URL, dest string) error {
+	return b.DownloadFileResumableContext(context.Background(), url_, dest)
+}
+
+// Downloads into a partial file that survives failures, so that calling this
+// again only fetches whatever is still missing. If the remote file changes
+// in between, the partial file is discarded and the download starts over.
+func (b *BlobStoreClient) DownloadFileResumableContext(ctx context.Context, url_ *url.URL, dest string) error {
+	stat, err := b.StatFileContext(ctx, url_)
+	if err != nil {
+		return err
+	}
+
+	if !stat.Exists {
+		return &BlobStoreHttpError{
+			Operation:  "Download",
+			Path:       url_.Path,
+			StatusCode: http.StatusNotFound,
+		}
+	}
+
+	state := partialDownloadState{
+		Path:      url_.Path,
+		SizeBytes: stat.SizeBytes,
+		Validator: statValidator(stat),
+	}
+
+	partialPath := dest + PartialDownloadSuffix
+	offset, err := resumableOffset(dest, &state)
+	if err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if offset == 0 {
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+
+		if err := writePartialDownloadState(dest+PartialDownloadStateSuffix, &state); err != nil {
+			return err
+		}
+
+		flags |= os.O_TRUNC
+	}
+
+	partialFile, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	if offset < int64(state.SizeBytes) {
+		err = b.appendRemainingContents(ctx, url_, &state, offset, partialFile)
+	}
+
+	if closeErr := partialFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	// Without a validator, nothing downloaded so far could be trusted by a
+	// later attempt.
+	if err == errRemoteFileChanged || (err != nil && state.Validator == "") {
+		removePartialDownload(dest)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(partialPath)
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != int64(state.SizeBytes) {
+		removePartialDownload(dest)
+		return errors.New("Downloaded file does not match the size of the file on the blobstore")
+	}
+
+	if err := os.Rename(partialPath, dest); err != nil {
+		return err
+	}
+
+	os.Remove(dest + PartialDownloadStateSuffix)
+	return nil
+}
+
+func (b *BlobStoreClient) appendRemainingContents(ctx context.Context, url_ *url.URL, state *partialDownloadState, offset int64, w io.Writer) error {
+	f, err := b.apiClient.GetFileRangeContext(ctx, url_.Path, offset, -1)
+	if err != nil {
+		return err
+	}
+	defer f.Contents.Close()
+
+	// The file may have been replaced since it was last checked.
+	if validator := statValidator(&f.Info); validator != "" && validator != state.Validator {
+		return errRemoteFileChanged
+	}
+
+	_, err = io.Copy(w, f.Contents)
+	return err
+}
@@ -0,0 +1,175 @@
+package blob
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	RemoteTestResumableContents = "abcdefghij"
+	RemoteTestResumableETag     = "\"abc123\""
+)
+
+func resumableStatMock(t *testing.T, etag string) HttpMockedMethod {
+	return func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		assert.Equal(t, RemoteTestStatHttpMethod, request.Method)
+
+		response := http.Response{
+			StatusCode: 200,
+			Header:     http.Header{},
+			Request:    request,
+		}
+		response.Header.Set("Content-Length", "10")
+		response.Header.Set("ETag", etag)
+		return &response, nil
+	}
+}
+
+func resumableRangeMock(t *testing.T, expectedRange string, body io.Reader) HttpMockedMethod {
+	return func(params ...interface{}) (*http.Response, error) {
+		request := params[0].(*http.Request)
+
+		assert.Equal(t, RemoteTestDownloadHttpMethod, request.Method)
+		assert.Equal(t, expectedRange, request.Header.Get("Range"))
+
+		response := http.Response{
+			StatusCode: 206,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(body),
+			Request:    request,
+		}
+		response.Header.Set("ETag", RemoteTestResumableETag)
+		return &response, nil
+	}
+}
+
+func TestDownloadFileResumableFresh(t *testing.T) {
+	api := testClient()
+
+	api.apiClient.(*BlobStoreApiClient).http = &TestDrivenHttpClient{[]HttpMockedMethod{
+		resumableStatMock(t, RemoteTestResumableETag),
+		resumableRangeMock(t, "bytes=0-", strings.NewReader(RemoteTestResumableContents)),
+	}}
+
+	tempDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	dest := filepath.Join(tempDir, "temp_file")
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.Nil(t, err)
+
+	contents, err := ioutil.ReadFile(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, RemoteTestResumableContents, string(contents))
+
+	files, err := ioutil.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestDownloadFileResumableInterrupted(t *testing.T) {
+	api := testClient()
+
+	api.apiClient.(*BlobStoreApiClient).http = &TestDrivenHttpClient{[]HttpMockedMethod{
+		resumableStatMock(t, RemoteTestResumableETag),
+		resumableRangeMock(t, "bytes=0-", io.MultiReader(strings.NewReader("abcd"), &failingReader{})),
+		resumableStatMock(t, RemoteTestResumableETag),
+		resumableRangeMock(t, "bytes=4-", strings.NewReader("efghij")),
+	}}
+
+	tempDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	dest := filepath.Join(tempDir, "temp_file")
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.Equal(t, "connection reset by peer", err.Error())
+
+	contents, err := ioutil.ReadFile(dest + PartialDownloadSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "abcd", string(contents))
+
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.Nil(t, err)
+
+	contents, err = ioutil.ReadFile(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, RemoteTestResumableContents, string(contents))
+
+	_, err = os.Stat(dest + PartialDownloadStateSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadFileResumableRemoteChanged(t *testing.T) {
+	api := testClient()
+
+	api.apiClient.(*BlobStoreApiClient).http = &TestDrivenHttpClient{[]HttpMockedMethod{
+		resumableStatMock(t, RemoteTestResumableETag),
+		resumableRangeMock(t, "bytes=0-", io.MultiReader(strings.NewReader("abcd"), &failingReader{})),
+		resumableStatMock(t, "\"def456\""),
+		resumableRangeMock(t, "bytes=0-", strings.NewReader(RemoteTestResumableContents)),
+	}}
+
+	tempDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	dest := filepath.Join(tempDir, "temp_file")
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.NotNil(t, err)
+
+	// The second range response carries the original ETag, which no longer
+	// matches what the stat saw, so the partial file has to be abandoned.
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.Equal(t, errRemoteFileChanged, err)
+
+	_, err = os.Stat(dest + PartialDownloadSuffix)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(dest + PartialDownloadStateSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadFileResumableWithoutValidator(t *testing.T) {
+	api := testClient()
+
+	rangeMock := resumableRangeMock(t, "bytes=0-", io.MultiReader(strings.NewReader("abcd"), &failingReader{}))
+	api.apiClient.(*BlobStoreApiClient).http = &TestDrivenHttpClient{[]HttpMockedMethod{
+		resumableStatMock(t, ""),
+		func(params ...interface{}) (*http.Response, error) {
+			response, err := rangeMock(params...)
+			response.Header.Del("ETag")
+			return response, err
+		},
+		resumableStatMock(t, ""),
+	}}
+
+	tempDir, err := ioutil.TempDir("", "")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Nothing could ever prove the partial file still matches, so it isn't
+	// kept around.
+	dest := filepath.Join(tempDir, "temp_file")
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.Equal(t, "connection reset by peer", err.Error())
+
+	_, err = os.Stat(dest + PartialDownloadSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, ioutil.WriteFile(dest+PartialDownloadSuffix, []byte("abcd"), 0644))
+
+	err = api.DownloadFileResumable(RemoteTestURL, dest)
+	assert.Equal(t, "Cannot resume download of "+RemoteTestURL.Path+", because the blobstore reports neither an ETag nor a Last-Modified time for it; remove "+dest+PartialDownloadSuffix+" to download it again", err.Error())
+}
@@ -0,0 +1,65 @@
+package blob
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Second * 8,
+	}
+
+	cases := []struct {
+		Attempt int
+		Ceiling time.Duration
+	}{
+		{1, time.Second},
+		{2, time.Second * 2},
+		{3, time.Second * 4},
+		{4, time.Second * 8},
+		{5, time.Second * 8},
+		{50, time.Second * 8},
+	}
+
+	for _, ti := range cases {
+		delay := policy.backoff(ti.Attempt)
+		assert.True(t, delay >= ti.Ceiling/2, "attempt %d waited %s", ti.Attempt, delay)
+		assert.True(t, delay <= ti.Ceiling, "attempt %d waited %s", ti.Attempt, delay)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		StatusCode int
+		Header     string
+		Delay      time.Duration
+		Ok         bool
+	}{
+		{429, "3", time.Second * 3, true},
+		{503, "0", 0, true},
+		{503, "Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+		{503, "", 0, false},
+		{503, "soon", 0, false},
+		{502, "3", 0, false},
+	}
+
+	for _, ti := range cases {
+		response := http.Response{
+			StatusCode: ti.StatusCode,
+			Header:     http.Header{},
+		}
+		response.Header.Set("Retry-After", ti.Header)
+
+		delay, ok := retryAfter(&response)
+		assert.Equal(t, ti.Ok, ok)
+		assert.Equal(t, ti.Delay, delay)
+	}
+}
@@ -0,0 +1,420 @@
+package blob
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type SyncActionKind string
+
+const (
+	SyncUpload   SyncActionKind = "upload"
+	SyncDownload SyncActionKind = "download"
+	SyncDelete   SyncActionKind = "delete"
+)
+
+type SyncOptions struct {
+	// Remove files from the destination that aren't in the source.
+	Delete bool
+	// Refuse to sync at all if more than this many files would be deleted.
+	// Negative values allow any number of deletions.
+	MaxDelete int
+
+	// Glob patterns matched against paths relative to the source. Patterns
+	// without a slash are also matched against file names alone.
+	Include []string
+	Exclude []string
+}
+
+type SyncAction struct {
+	Kind         SyncActionKind
+	RelativePath string
+	Source       *url.URL
+	Destination  *url.URL
+	Reason       string
+
+	modTime time.Time
+}
+
+type SyncPlan struct {
+	Actions   []SyncAction
+	Unchanged []string
+}
+
+func (p *SyncPlan) Deletes() int {
+	deletes := 0
+	for _, action := range p.Actions {
+		if action.Kind == SyncDelete {
+			deletes++
+		}
+	}
+	return deletes
+}
+
+type syncEntry struct {
+	relativePath string
+	url          *url.URL
+	sizeBytes    int64
+	modTime      time.Time
+	etag         string
+}
+
+func (o *SyncOptions) validate() error {
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid filter pattern %s: %s", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func syncPatternsMatch(patterns []string, relativePath string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, relativePath); ok {
+			return true
+		}
+
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(relativePath)); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Files that are filtered out are neither transferred nor deleted.
+func (o *SyncOptions) includes(relativePath string) bool {
+	if len(o.Include) != 0 && !syncPatternsMatch(o.Include, relativePath) {
+		return false
+	}
+
+	return !syncPatternsMatch(o.Exclude, relativePath)
+}
+
+func listLocalSyncEntries(root string, options *SyncOptions) (map[string]*syncEntry, error) {
+	entries := map[string]*syncEntry{}
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return entries, nil
+	}
+
+	err := filepath.Walk(root, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, localPath)
+		if err != nil {
+			return err
+		}
+
+		relativePath = filepath.ToSlash(relativePath)
+		if !options.includes(relativePath) {
+			return nil
+		}
+
+		entries[relativePath] = &syncEntry{
+			relativePath: relativePath,
+			url:          &url.URL{Path: localPath},
+			sizeBytes:    info.Size(),
+			modTime:      info.ModTime(),
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+func (b *BlobStoreClient) listRemoteSyncEntries(ctx context.Context, prefixUrl *url.URL, options *SyncOptions) (map[string]*syncEntry, error) {
+	prefix := strings.TrimLeft(prefixUrl.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	// One listing gives the size, time and ETag of every file, rather than
+	// asking about each of them in turn.
+	stats, err := b.ListPrefixStatContext(ctx, prefixUrl.Path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]*syncEntry{}
+	for _, stat := range stats {
+		key := stat.Path + stat.Name
+		if !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		relativePath := key[len(prefix):]
+		if relativePath == "" || !options.includes(relativePath) {
+			continue
+		}
+
+		entries[relativePath] = &syncEntry{
+			relativePath: relativePath,
+			url:          &url.URL{Scheme: BlobStoreUrlScheme, Path: key},
+			sizeBytes:    int64(stat.SizeBytes),
+			modTime:      stat.LastModified,
+			etag:         stat.ETag,
+		}
+	}
+
+	return entries, nil
+}
+
+// An ETag only doubles as a checksum when it looks like an MD5 digest.
+func etagChecksum(etag string) (string, bool) {
+	etag = strings.Trim(strings.TrimPrefix(etag, "W/"), "\"")
+	if len(etag) != md5.Size*2 {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(etag); err != nil {
+		return "", false
+	}
+
+	return strings.ToLower(etag), true
+}
+
+func localFileChecksum(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Decides whether the source needs to be copied over the destination, and
+// why. Checksums are preferred, but when the blobstore doesn't provide one,
+// modification times are the fallback.
+func syncEntryDiffers(local *syncEntry, remote *syncEntry, upload bool) (string, error) {
+	source, destination := remote, local
+	if upload {
+		source, destination = local, remote
+	}
+
+	if source.sizeBytes != destination.sizeBytes {
+		return "size differs", nil
+	}
+
+	if remoteChecksum, ok := etagChecksum(remote.etag); ok {
+		localChecksum, err := localFileChecksum(local.url.Path)
+		if err != nil {
+			return "", err
+		}
+
+		if localChecksum != remoteChecksum {
+			return "checksum differs", nil
+		}
+
+		return "", nil
+	}
+
+	if !source.modTime.IsZero() && !destination.modTime.IsZero() {
+		// The blobstore only reports times to the second.
+		if source.modTime.Truncate(time.Second).After(destination.modTime.Truncate(time.Second)) {
+			return "source is newer", nil
+		}
+	}
+
+	return "", nil
+}
+
+func (b *BlobStoreClient) PlanSync(src *url.URL, dst *url.URL, options SyncOptions) (*SyncPlan, error) {
+	return b.PlanSyncContext(context.Background(), src, dst, options)
+}
+
+// Works out what has to happen to make dst mirror src, without changing
+// anything. If more deletions are needed than options allow, the plan is
+// still returned along with an error, so that it can be inspected.
+func (b *BlobStoreClient) PlanSyncContext(ctx context.Context, src *url.URL, dst *url.URL, options SyncOptions) (*SyncPlan, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	upload := src.Scheme != BlobStoreUrlScheme && dst.Scheme == BlobStoreUrlScheme
+	download := src.Scheme == BlobStoreUrlScheme && dst.Scheme != BlobStoreUrlScheme
+	if !upload && !download {
+		return nil, errors.New("Must sync between exactly one local directory and one blob:/ prefix")
+	}
+
+	var localEntries, remoteEntries map[string]*syncEntry
+	var err error
+
+	localRoot, remotePrefix := src, dst
+	if download {
+		localRoot, remotePrefix = dst, src
+	}
+
+	localEntries, err = listLocalSyncEntries(localRoot.Path, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteEntries, err = b.listRemoteSyncEntries(ctx, remotePrefix, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceEntries, destinationEntries := localEntries, remoteEntries
+	kind := SyncUpload
+	if download {
+		sourceEntries, destinationEntries = remoteEntries, localEntries
+		kind = SyncDownload
+	}
+
+	plan := SyncPlan{
+		Actions:   []SyncAction{},
+		Unchanged: []string{},
+	}
+
+	for _, relativePath := range sortedSyncPaths(sourceEntries) {
+		source := sourceEntries[relativePath]
+		destinationUrl, err := syncDestinationUrl(dst, relativePath)
+		if err != nil {
+			return nil, err
+		}
+
+		reason := "new"
+		if _, ok := destinationEntries[relativePath]; ok {
+			reason, err = syncEntryDiffers(localEntries[relativePath], remoteEntries[relativePath], upload)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if reason == "" {
+			plan.Unchanged = append(plan.Unchanged, relativePath)
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, SyncAction{
+			Kind:         kind,
+			RelativePath: relativePath,
+			Source:       source.url,
+			Destination:  destinationUrl,
+			Reason:       reason,
+			modTime:      source.modTime,
+		})
+	}
+
+	if options.Delete {
+		for _, relativePath := range sortedSyncPaths(destinationEntries) {
+			if _, ok := sourceEntries[relativePath]; ok {
+				continue
+			}
+
+			plan.Actions = append(plan.Actions, SyncAction{
+				Kind:         SyncDelete,
+				RelativePath: relativePath,
+				Destination:  destinationEntries[relativePath].url,
+				Reason:       "not in source",
+			})
+		}
+
+		if deletes := plan.Deletes(); options.MaxDelete >= 0 && deletes > options.MaxDelete {
+			return &plan, fmt.Errorf("Refusing to delete %d files, which is more than the limit of %d", deletes, options.MaxDelete)
+		}
+	}
+
+	return &plan, nil
+}
+
+func sortedSyncPaths(entries map[string]*syncEntry) []string {
+	paths := make([]string, 0, len(entries))
+	for relativePath := range entries {
+		paths = append(paths, relativePath)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func syncDestinationUrl(dst *url.URL, relativePath string) (*url.URL, error) {
+	if dst.Scheme == BlobStoreUrlScheme {
+		return &url.URL{Scheme: BlobStoreUrlScheme, Path: path.Join(dst.Path, relativePath)}, nil
+	}
+
+	localPath, err := localPathWithin(dst.Path, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{Path: localPath}, nil
+}
+
+func (b *BlobStoreClient) ApplySyncPlan(plan *SyncPlan) (*TransferSummary, error) {
+	return b.ApplySyncPlanContext(context.Background(), plan)
+}
+
+func (b *BlobStoreClient) ApplySyncPlanContext(ctx context.Context, plan *SyncPlan) (*TransferSummary, error) {
+	summary := NewTransferSummary()
+	summary.Skipped = append(summary.Skipped, plan.Unchanged...)
+
+	jobs := make([]TransferJob, 0, len(plan.Actions))
+	for _, action := range plan.Actions {
+		job, err := syncActionJob(&action)
+		if err != nil {
+			summary.Failed = append(summary.Failed, TransferError{action.RelativePath, err})
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	transferred, err := b.newTransferManager().RunContext(ctx, jobs)
+	summary.merge(transferred)
+
+	return summary, err
+}
+
+func syncActionJob(action *SyncAction) (TransferJob, error) {
+	job := TransferJob{
+		Source:      action.Source,
+		Destination: action.Destination,
+		Force:       true,
+		Path:        action.RelativePath,
+	}
+
+	switch action.Kind {
+	case SyncUpload:
+		job.Kind = TransferUpload
+		job.ContentType = mime.TypeByExtension(path.Ext(action.RelativePath))
+	case SyncDownload:
+		// Match the blobstore's time, so the next sync sees them as equal.
+		job.Kind = TransferDownload
+		job.ModTime = action.modTime
+	case SyncDelete:
+		job.Kind = TransferDelete
+		job.Source = action.Destination
+	default:
+		return job, fmt.Errorf("Unknown sync action %s", action.Kind)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,177 @@
+package blob
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func TestSyncUpload(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"same.txt":      "same",
+		"changed.txt":   "changed",
+		"new/file.txt":  "new",
+		"ignored.log":   "ignored",
+		"new/other.log": "ignored",
+	})
+	defer os.RemoveAll(root)
+
+	server.Put("prefix/same.txt", []byte("same"), "text/plain")
+	server.Put("prefix/changed.txt", []byte("chanxed"), "text/plain")
+	server.Put("prefix/extra.txt", []byte("extra"), "text/plain")
+	server.Put("prefix/kept.log", []byte("kept"), "text/plain")
+
+	src := &url.URL{Path: root}
+	dst, _ := url.Parse("blob:/prefix")
+	options := SyncOptions{
+		Delete:    true,
+		MaxDelete: 1,
+		Exclude:   []string{"*.log"},
+	}
+
+	plan, err := api.PlanSync(src, dst, options)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"same.txt"}, plan.Unchanged)
+	assert.Equal(t, 3, len(plan.Actions))
+	assert.Equal(t, SyncAction{Kind: SyncUpload, RelativePath: "changed.txt", Source: &url.URL{Path: filepath.Join(root, "changed.txt")}, Destination: &url.URL{Scheme: "blob", Path: "/prefix/changed.txt"}, Reason: "checksum differs", modTime: plan.Actions[0].modTime}, plan.Actions[0])
+	assert.Equal(t, SyncUpload, plan.Actions[1].Kind)
+	assert.Equal(t, "new/file.txt", plan.Actions[1].RelativePath)
+	assert.Equal(t, "new", plan.Actions[1].Reason)
+	assert.Equal(t, SyncDelete, plan.Actions[2].Kind)
+	assert.Equal(t, "extra.txt", plan.Actions[2].RelativePath)
+
+	summary, err := api.ApplySyncPlan(plan)
+	assert.Nil(t, err)
+	assert.Nil(t, summary.Err())
+	assert.Equal(t, []string{"changed.txt", "new/file.txt", "extra.txt"}, summary.Transferred)
+	assert.Equal(t, []string{"same.txt"}, summary.Skipped)
+
+	assert.Equal(t, "changed", storedContents(server, "prefix/changed.txt"))
+	assert.Equal(t, "new", storedContents(server, "prefix/new/file.txt"))
+	assert.Equal(t, "kept", storedContents(server, "prefix/kept.log"))
+	_, exists := server.Get("prefix/extra.txt")
+	assert.False(t, exists)
+	_, exists = server.Get("prefix/ignored.log")
+	assert.False(t, exists)
+
+	// Everything should now be in sync.
+	plan, err = api.PlanSync(src, dst, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(plan.Actions))
+}
+
+func TestSyncDownloadModificationTimes(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"stale.txt":   "abc",
+		"current.txt": "abc",
+		"extra.txt":   "extra",
+	})
+	defer os.RemoveAll(root)
+
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	assert.Nil(t, os.Chtimes(filepath.Join(root, "stale.txt"), past, past))
+	assert.Nil(t, os.Chtimes(filepath.Join(root, "current.txt"), future, future))
+
+	// Opaque ETags leave modification times as the only way to compare.
+	server.PutObject("prefix/stale.txt", blobtest.Object{Contents: []byte("def"), ETag: "\"stale\"", ModTime: past.Add(time.Minute)})
+	server.PutObject("prefix/current.txt", blobtest.Object{Contents: []byte("def"), ETag: "\"current\"", ModTime: past})
+
+	src, _ := url.Parse("blob:/prefix")
+	dst := &url.URL{Path: root}
+
+	requests := server.Requests()
+	plan, err := api.PlanSync(src, dst, SyncOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, server.Requests()-requests)
+	assert.Equal(t, []string{"current.txt"}, plan.Unchanged)
+	assert.Equal(t, 1, len(plan.Actions))
+	assert.Equal(t, "stale.txt", plan.Actions[0].RelativePath)
+	assert.Equal(t, "source is newer", plan.Actions[0].Reason)
+
+	summary, err := api.ApplySyncPlan(plan)
+	assert.Nil(t, err)
+	assert.Nil(t, summary.Err())
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "stale.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "def", string(contents))
+
+	info, err := os.Stat(filepath.Join(root, "stale.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, past.Add(time.Minute), info.ModTime().UTC())
+
+	_, err = os.Stat(filepath.Join(root, "extra.txt"))
+	assert.Nil(t, err)
+}
+
+func TestSyncDeleteLimit(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{})
+	defer os.RemoveAll(root)
+
+	server.Put("prefix/one.txt", []byte("one"), "text/plain")
+	server.Put("prefix/two.txt", []byte("two"), "text/plain")
+
+	src := &url.URL{Path: root}
+	dst, _ := url.Parse("blob:/prefix")
+
+	plan, err := api.PlanSync(src, dst, SyncOptions{Delete: true, MaxDelete: 1})
+	assert.Equal(t, "Refusing to delete 2 files, which is more than the limit of 1", err.Error())
+	assert.Equal(t, 2, plan.Deletes())
+
+	plan, err = api.PlanSync(src, dst, SyncOptions{Delete: true, MaxDelete: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, plan.Deletes())
+}
+
+func TestSyncInclude(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"reports/a.csv": "a",
+		"reports/b.txt": "b",
+		"c.csv":         "c",
+	})
+	defer os.RemoveAll(root)
+
+	src := &url.URL{Path: root}
+	dst, _ := url.Parse("blob:/prefix")
+
+	plan, err := api.PlanSync(src, dst, SyncOptions{Include: []string{"reports/*.csv"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(plan.Actions))
+	assert.Equal(t, "reports/a.csv", plan.Actions[0].RelativePath)
+
+	_, err = api.PlanSync(src, dst, SyncOptions{Include: []string{"[a-"}})
+	assert.Equal(t, "Invalid filter pattern [a-: syntax error in pattern", err.Error())
+}
+
+func TestSyncRequiresOneBlobPath(t *testing.T) {
+	api := testClient()
+
+	_, err := api.PlanSync(&url.URL{Path: "a"}, &url.URL{Path: "b"}, SyncOptions{})
+	assert.Equal(t, "Must sync between exactly one local directory and one blob:/ prefix", err.Error())
+}
@@ -0,0 +1,228 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const DefaultTransferParallelism int = 4
+
+type TransferJobKind string
+
+const (
+	TransferUpload   TransferJobKind = "upload"
+	TransferDownload TransferJobKind = "download"
+	TransferCopy     TransferJobKind = "copy"
+	TransferMove     TransferJobKind = "move"
+	TransferDelete   TransferJobKind = "delete"
+)
+
+// A single file for a TransferManager to work on. Deletes remove Source,
+// which may be local or on the blobstore, and ignore Destination.
+//
+// Unless Force is set, a job whose destination already exists is skipped.
+type TransferJob struct {
+	Kind        TransferJobKind
+	Source      *url.URL
+	Destination *url.URL
+	Force       bool
+
+	// Reported in the summary; defaults to the source's path.
+	Path string
+
+	// Only used by uploads.
+	ContentType string
+
+	// Set as the modification time of downloaded files, if not zero.
+	ModTime time.Time
+}
+
+func (j *TransferJob) summaryPath() string {
+	if j.Path != "" {
+		return j.Path
+	}
+
+	return j.Source.Path
+}
+
+// In fail fast mode, the first job to fail stops any others from starting,
+// and is returned as the error. Otherwise every job is attempted, and the
+// failures are only reported in the summary.
+type TransferOptions struct {
+	Parallel int
+	FailFast bool
+}
+
+func DefaultTransferOptions() TransferOptions {
+	return TransferOptions{
+		Parallel: DefaultTransferParallelism,
+	}
+}
+
+// Runs bulk operations on a bounded number of workers, all sharing the
+// client, and so its connections.
+type TransferManager struct {
+	client  *BlobStoreClient
+	options TransferOptions
+}
+
+func NewTransferManager(client *BlobStoreClient, options TransferOptions) *TransferManager {
+	if options.Parallel < 1 {
+		options.Parallel = 1
+	}
+
+	return &TransferManager{client, options}
+}
+
+type transferOutcome int
+
+const (
+	transferNotRun transferOutcome = iota
+	transferDone
+	transferSkipped
+	transferFailed
+)
+
+func (m *TransferManager) Run(jobs []TransferJob) (*TransferSummary, error) {
+	return m.RunContext(context.Background(), jobs)
+}
+
+// Results appear in the summary in the same order as the jobs, regardless
+// of the order they finish in. Jobs that never ran because of cancellation,
+// or an earlier failure in fail fast mode, are left out of it.
+func (m *TransferManager) RunContext(ctx context.Context, jobs []TransferJob) (*TransferSummary, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make([]transferOutcome, len(jobs))
+	errs := make([]error, len(jobs))
+
+	var lock sync.Mutex
+	var firstFailure *TransferError
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < m.options.Parallel && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				skipped, err := m.runJob(runCtx, &jobs[i])
+
+				lock.Lock()
+				switch {
+				case err != nil && runCtx.Err() != nil:
+					outcomes[i] = transferNotRun
+				case err != nil:
+					outcomes[i] = transferFailed
+					errs[i] = err
+					if m.options.FailFast && firstFailure == nil {
+						firstFailure = &TransferError{jobs[i].summaryPath(), err}
+						cancel()
+					}
+				case skipped:
+					outcomes[i] = transferSkipped
+				default:
+					outcomes[i] = transferDone
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range jobs {
+		select {
+		case work <- i:
+		case <-runCtx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	summary := NewTransferSummary()
+	for i := range jobs {
+		switch outcomes[i] {
+		case transferDone:
+			summary.Transferred = append(summary.Transferred, jobs[i].summaryPath())
+		case transferSkipped:
+			summary.Skipped = append(summary.Skipped, jobs[i].summaryPath())
+		case transferFailed:
+			summary.Failed = append(summary.Failed, TransferError{jobs[i].summaryPath(), errs[i]})
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+
+	if firstFailure != nil {
+		return summary, firstFailure
+	}
+
+	return summary, nil
+}
+
+func (m *TransferManager) runJob(ctx context.Context, job *TransferJob) (bool, error) {
+	b := m.client
+
+	if !job.Force && job.Kind != TransferDelete {
+		exists, err := b.ExistsContext(ctx, job.Destination)
+		if err != nil {
+			return false, err
+		}
+
+		if exists {
+			return true, nil
+		}
+	}
+
+	switch job.Kind {
+	case TransferUpload:
+		return false, b.UploadFileContext(ctx, job.Destination, job.Source.Path, job.ContentType)
+	case TransferDownload:
+		if err := b.DownloadFileContext(ctx, job.Source, job.Destination.Path); err != nil {
+			return false, err
+		}
+
+		if !job.ModTime.IsZero() {
+			return false, os.Chtimes(job.Destination.Path, time.Now(), job.ModTime)
+		}
+		return false, nil
+	case TransferCopy:
+		return false, b.CopyContext(ctx, job.Source, job.Destination, true)
+	case TransferMove:
+		return false, b.MoveContext(ctx, job.Source, job.Destination, true)
+	case TransferDelete:
+		// Anything that's already gone doesn't need deleting.
+		if job.Source.Scheme == BlobStoreUrlScheme {
+			if err := b.DeleteFileContext(ctx, job.Source); err != nil && !errors.Is(err, ErrNotFound) {
+				return false, err
+			}
+			return false, nil
+		}
+
+		if err := os.Remove(job.Source.Path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Unknown transfer job %s", job.Kind)
+}
+
+func (b *BlobStoreClient) Transfer(jobs []TransferJob) (*TransferSummary, error) {
+	return b.TransferContext(context.Background(), jobs)
+}
+
+// Runs the jobs with the client's transfer options.
+func (b *BlobStoreClient) TransferContext(ctx context.Context, jobs []TransferJob) (*TransferSummary, error) {
+	return b.newTransferManager().RunContext(ctx, jobs)
+}
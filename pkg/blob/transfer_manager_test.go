@@ -0,0 +1,136 @@
+package blob
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blobtest"
+)
+
+func deleteJobs(keys ...string) []TransferJob {
+	jobs := []TransferJob{}
+	for _, key := range keys {
+		jobs = append(jobs, TransferJob{
+			Kind:   TransferDelete,
+			Source: &url.URL{Scheme: BlobStoreUrlScheme, Path: "/" + key},
+			Path:   key,
+		})
+	}
+	return jobs
+}
+
+func TestTransferManagerBestEffort(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	root := writeTestTree(t, map[string]string{
+		"a.txt":        "a",
+		"b.txt":        "b",
+		"existing.txt": "new",
+	})
+	defer os.RemoveAll(root)
+
+	server.Put("dst/existing.txt", []byte("old"), "")
+	server.Put("src/c.txt", []byte("c"), "")
+	server.Fail("dst/b.txt", 400)
+
+	jobs := []TransferJob{}
+	for _, name := range []string{"a.txt", "b.txt", "existing.txt"} {
+		jobs = append(jobs, TransferJob{
+			Kind:        TransferUpload,
+			Source:      &url.URL{Path: filepath.Join(root, name)},
+			Destination: &url.URL{Scheme: BlobStoreUrlScheme, Path: "/dst/" + name},
+			ContentType: "text/plain",
+		})
+	}
+	jobs = append(jobs, TransferJob{
+		Kind:        TransferCopy,
+		Source:      &url.URL{Scheme: BlobStoreUrlScheme, Path: "/src/c.txt"},
+		Destination: &url.URL{Scheme: BlobStoreUrlScheme, Path: "/dst/c.txt"},
+	})
+	jobs = append(jobs, deleteJobs("src/c.txt", "src/missing.txt")...)
+
+	summary, err := NewTransferManager(api, TransferOptions{Parallel: 1}).Run(jobs)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{filepath.Join(root, "a.txt"), "/src/c.txt", "src/c.txt", "src/missing.txt"}, summary.Transferred)
+	assert.Equal(t, []string{filepath.Join(root, "existing.txt")}, summary.Skipped)
+	assert.Equal(t, 1, len(summary.Failed))
+	assert.Equal(t, filepath.Join(root, "b.txt"), summary.Failed[0].Path)
+
+	assert.Equal(t, "a", storedContents(server, "dst/a.txt"))
+	assert.Equal(t, "text/plain", storedContentType(server, "dst/a.txt"))
+	assert.Equal(t, "old", storedContents(server, "dst/existing.txt"))
+	assert.Equal(t, "c", storedContents(server, "dst/c.txt"))
+	_, exists := server.Get("src/c.txt")
+	assert.False(t, exists)
+}
+
+func TestTransferManagerFailFast(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{})
+	defer server.Close()
+
+	for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+		server.Put(key, []byte(key), "")
+	}
+	server.Fail("b.txt", 400)
+
+	summary, err := NewTransferManager(api, TransferOptions{Parallel: 1, FailFast: true}).Run(deleteJobs("a.txt", "b.txt", "c.txt"))
+
+	var transferError *TransferError
+	assert.True(t, errors.As(err, &transferError))
+	assert.Equal(t, "b.txt", transferError.Path)
+
+	assert.Equal(t, []string{"a.txt"}, summary.Transferred)
+	assert.Equal(t, []TransferError{*transferError}, summary.Failed)
+
+	_, exists := server.Get("c.txt")
+	assert.True(t, exists)
+}
+
+func TestTransferManagerParallel(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{Latency: 10 * time.Millisecond})
+	defer server.Close()
+
+	keys := []string{}
+	for i := 0; i < 12; i++ {
+		keys = append(keys, string(rune('a'+i)))
+		server.Put(keys[i], []byte(keys[i]), "")
+	}
+
+	summary, err := NewTransferManager(api, TransferOptions{Parallel: 3}).Run(deleteJobs(keys...))
+	assert.Nil(t, err)
+	assert.Equal(t, keys, summary.Transferred)
+
+	assert.Equal(t, 3, server.MaxConcurrentRequests())
+}
+
+func TestSetTransferOptions(t *testing.T) {
+	server, api := testServerClient(blobtest.Config{Latency: 10 * time.Millisecond})
+	defer server.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		server.Put(key, []byte(key), "")
+	}
+
+	api.SetTransferOptions(TransferOptions{Parallel: 2})
+
+	_, err := api.DeleteFiles([]*url.URL{
+		{Scheme: BlobStoreUrlScheme, Path: "/a"},
+		{Scheme: BlobStoreUrlScheme, Path: "/b"},
+		{Scheme: BlobStoreUrlScheme, Path: "/c"},
+		{Scheme: BlobStoreUrlScheme, Path: "/d"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, server.MaxConcurrentRequests())
+}
@@ -0,0 +1,52 @@
+package blob
+
+import (
+	"fmt"
+)
+
+type TransferError struct {
+	Path string
+	Err  error
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *TransferError) Unwrap() error {
+	return e.Err
+}
+
+// Outcome of an operation touching many files, which carries on past
+// individual failures so that they can all be reported at the end.
+type TransferSummary struct {
+	Transferred []string
+	Skipped     []string
+	Failed      []TransferError
+}
+
+func NewTransferSummary() *TransferSummary {
+	return &TransferSummary{
+		Transferred: []string{},
+		Skipped:     []string{},
+		Failed:      []TransferError{},
+	}
+}
+
+func (s *TransferSummary) merge(other *TransferSummary) {
+	s.Transferred = append(s.Transferred, other.Transferred...)
+	s.Skipped = append(s.Skipped, other.Skipped...)
+	s.Failed = append(s.Failed, other.Failed...)
+}
+
+func (s *TransferSummary) Total() int {
+	return len(s.Transferred) + len(s.Skipped) + len(s.Failed)
+}
+
+func (s *TransferSummary) Err() error {
+	if len(s.Failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Failed to transfer %d of %d files", len(s.Failed), s.Total())
+}
@@ -0,0 +1,446 @@
+// Package blobtest provides an in-process blobstore, for testing code that
+// talks to a blobstore without needing network access or real credentials.
+package blobtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
+)
+
+const (
+	HttpResponseRequestIdHeader = "X-Request-Id"
+
+	// Headers with this prefix sent with an upload are returned with the file.
+	HttpMetadataHeaderPrefix = "X-Blobstore-Meta-"
+
+	ListPrefixPathComponent = "_dir"
+)
+
+const notFoundResponseBody = `{"code":"NotFound","message":"File not found"}`
+
+// Any ACL left empty isn't enforced, so anyone can perform those operations.
+//
+// SigningKeys maps key ids to secrets for requests signed by a
+// credential_provider.SigningCredentialProvider. Validly signed requests can
+// perform any operation, and badly signed ones are always refused.
+//
+// Latency holds every request for a while before answering it, so that
+// concurrent requests overlap. PlainListings ignores stat=true, like a
+// server that can only list paths.
+type Config struct {
+	ReadAcl  string
+	WriteAcl string
+
+	SigningKeys  map[string]string
+	MaxClockSkew time.Duration
+
+	Latency       time.Duration
+	PlainListings bool
+}
+
+type signedRequestKey struct{}
+
+type listedFile struct {
+	Path         string     `json:"path"`
+	Size         int        `json:"size,omitempty"`
+	MimeType     string     `json:"mime_type,omitempty"`
+	ETag         string     `json:"etag,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
+
+type Object struct {
+	Contents    []byte
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+	Metadata    map[string]string
+}
+
+type Server struct {
+	URL string
+
+	config Config
+	server *httptest.Server
+
+	lock      sync.RWMutex
+	objects   map[string]*Object
+	failures  map[string]int
+	requestId int
+
+	inFlight    int
+	maxInFlight int
+}
+
+func NewServer(config Config) *Server {
+	s := Server{
+		config:   config,
+		objects:  map[string]*Object{},
+		failures: map[string]int{},
+	}
+
+	s.server = httptest.NewServer(&s)
+	s.URL = s.server.URL
+
+	return &s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func objectKey(p string) string {
+	return strings.TrimLeft(p, "/")
+}
+
+// Put stores a file directly, bypassing authorization, so that tests can
+// set up whatever they need before exercising a client.
+func (s *Server) Put(p string, contents []byte, contentType string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.putLocked(objectKey(p), contents, contentType)
+}
+
+// PutObject stores a file the same way as Put, but keeps any modification
+// time, ETag or metadata that the object already has.
+func (s *Server) PutObject(p string, object Object) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.putObjectLocked(objectKey(p), object)
+}
+
+func (s *Server) putLocked(key string, contents []byte, contentType string) {
+	s.putObjectLocked(key, Object{Contents: contents, ContentType: contentType})
+}
+
+func (s *Server) putObjectLocked(key string, object Object) {
+	if object.ContentType == "" {
+		object.ContentType = http.DetectContentType(object.Contents)
+	}
+	if object.ModTime.IsZero() {
+		object.ModTime = time.Now()
+	}
+	if object.ETag == "" {
+		object.ETag = fmt.Sprintf("\"%x\"", md5.Sum(object.Contents))
+	}
+
+	// Last-Modified only has a resolution of seconds.
+	object.ModTime = object.ModTime.UTC().Truncate(time.Second)
+
+	s.objects[key] = &object
+}
+
+func (s *Server) Get(p string) (*Object, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	object, ok := s.objects[objectKey(p)]
+	if !ok {
+		return nil, false
+	}
+
+	rv := *object
+	return &rv, true
+}
+
+func (s *Server) Delete(p string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.objects, objectKey(p))
+}
+
+// Keys lists every stored file, in order.
+func (s *Server) Keys() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Fail answers every request for a file with the given status code, without
+// doing anything else, until it's called again with 0.
+func (s *Server) Fail(p string, statusCode int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if statusCode == 0 {
+		delete(s.failures, objectKey(p))
+	} else {
+		s.failures[objectKey(p)] = statusCode
+	}
+}
+
+// Requests counts every request the server has received.
+func (s *Server) Requests() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.requestId
+}
+
+// MaxConcurrentRequests is the most requests the server has been handling at
+// the same time.
+func (s *Server) MaxConcurrentRequests() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.maxInFlight
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.requestId++
+	w.Header().Set(HttpResponseRequestIdHeader, strconv.Itoa(s.requestId))
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	failure := s.failures[objectKey(r.URL.Path)]
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.inFlight--
+		s.lock.Unlock()
+	}()
+
+	if s.config.Latency > 0 {
+		time.Sleep(s.config.Latency)
+	}
+
+	if failure != 0 {
+		w.WriteHeader(failure)
+		return
+	}
+
+	signed, err := s.verifySignature(r)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if signed {
+		r = r.WithContext(context.WithValue(r.Context(), signedRequestKey{}, true))
+	}
+
+	key := objectKey(r.URL.Path)
+
+	if key == ListPrefixPathComponent || strings.HasPrefix(key, ListPrefixPathComponent+"/") {
+		s.serveList(w, r, strings.TrimPrefix(strings.TrimPrefix(key, ListPrefixPathComponent), "/"))
+		return
+	}
+
+	switch r.Method {
+	case "GET", "HEAD":
+		s.serveGet(w, r, key)
+	case "POST":
+		s.servePost(w, r, key)
+	case "DELETE":
+		s.serveDelete(w, r, key)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) verifySignature(r *http.Request) (bool, error) {
+	maxSkew := s.config.MaxClockSkew
+	if maxSkew == 0 {
+		maxSkew = credential_provider.DefaultSignatureMaxClockSkew
+	}
+
+	lookup := func(keyId string) (string, bool) {
+		secret, ok := s.config.SigningKeys[keyId]
+		return secret, ok
+	}
+
+	err := credential_provider.VerifySignedRequest(r, lookup, time.Now(), maxSkew)
+	if errors.Is(err, credential_provider.ErrRequestNotSigned) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func isSigned(r *http.Request) bool {
+	signed, _ := r.Context().Value(signedRequestKey{}).(bool)
+	return signed
+}
+
+func (s *Server) authorizeRead(r *http.Request) bool {
+	return s.config.ReadAcl == "" || isSigned(r) || r.Header.Get(credential_provider.HttpRequestReadAclHeader) == s.config.ReadAcl
+}
+
+func (s *Server) authorizeWrite(r *http.Request) bool {
+	return s.config.WriteAcl == "" || isSigned(r) || r.Header.Get(credential_provider.HttpRequestWriteAclHeader) == s.config.WriteAcl
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(notFoundResponseBody))
+}
+
+func (s *Server) serveGet(w http.ResponseWriter, r *http.Request, key string) {
+	if !s.authorizeRead(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	object, ok := s.Get(key)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("ETag", object.ETag)
+	for header, value := range object.Metadata {
+		w.Header().Set(header, value)
+	}
+	http.ServeContent(w, r, path.Base(key), object.ModTime, bytes.NewReader(object.Contents))
+}
+
+func (s *Server) servePost(w http.ResponseWriter, r *http.Request, key string) {
+	if !s.authorizeWrite(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	contents, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	metadata := map[string]string{}
+	for header := range r.Header {
+		if strings.HasPrefix(header, HttpMetadataHeaderPrefix) {
+			metadata[header] = r.Header.Get(header)
+		}
+	}
+
+	s.lock.Lock()
+	s.putLocked(key, contents, r.Header.Get("Content-Type"))
+	s.objects[key].Metadata = metadata
+	s.lock.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) serveDelete(w http.ResponseWriter, r *http.Request, key string) {
+	if !s.authorizeWrite(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s.lock.Lock()
+	_, ok := s.objects[key]
+	delete(s.objects, key)
+	s.lock.Unlock()
+
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Lists everything below a prefix. Without recursion, anything nested more
+// deeply is collapsed into a single entry for its directory, which ends in
+// a slash.
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, prefix string) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorizeRead(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	recursive := r.URL.Query().Get("recursive") == "true"
+	stat := r.URL.Query().Get("stat") == "true" && !s.config.PlainListings
+
+	s.lock.RLock()
+	seen := map[string]bool{}
+	keys := []string{}
+	for key := range s.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if !recursive {
+			if slash := strings.Index(key[len(prefix):], "/"); slash != -1 {
+				key = key[:len(prefix)+slash+1]
+			}
+		}
+
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var listing interface{} = keys
+	if stat {
+		listing = s.statKeysLocked(keys)
+	}
+	s.lock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listing)
+}
+
+// Describes each listed file the way a listing requested with stat=true
+// expects. Directories only have a path.
+func (s *Server) statKeysLocked(keys []string) []listedFile {
+	rv := make([]listedFile, 0, len(keys))
+	for _, key := range keys {
+		object, ok := s.objects[key]
+		if !ok || strings.HasSuffix(key, "/") {
+			rv = append(rv, listedFile{Path: key})
+			continue
+		}
+
+		rv = append(rv, listedFile{
+			Path:         key,
+			Size:         len(object.Contents),
+			MimeType:     object.ContentType,
+			ETag:         object.ETag,
+			LastModified: &object.ModTime,
+		})
+	}
+
+	return rv
+}
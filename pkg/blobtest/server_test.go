@@ -0,0 +1,246 @@
+package blobtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/blob"
+	"github.com/Eagerod/blobstore-client/pkg/credential_provider"
+)
+
+const (
+	TestReadAcl  = "read secret"
+	TestWriteAcl = "write secret"
+)
+
+func toURL(path string) *url.URL {
+	url, err := url.Parse(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return url
+}
+
+func testServerAndClient(readAcl, writeAcl string) (*Server, *blob.BlobStoreClient) {
+	server := NewServer(Config{
+		ReadAcl:  TestReadAcl,
+		WriteAcl: TestWriteAcl,
+	})
+
+	client := blob.NewBlobStoreClient(server.URL, &credential_provider.DirectCredentialProvider{
+		ReadAcl:  readAcl,
+		WriteAcl: writeAcl,
+	})
+
+	return server, client
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	server, client := testServerAndClient(TestReadAcl, TestWriteAcl)
+	defer server.Close()
+
+	err := client.AppendString(toURL("blob:/path/to/file.txt"), "")
+	assert.True(t, errors.Is(err, blob.ErrNotFound))
+
+	server.Put("path/to/file.txt", []byte("abc"), "text/plain")
+
+	err = client.AppendString(toURL("blob:/path/to/file.txt"), "def")
+	assert.Nil(t, err)
+
+	contents, err := client.GetFileContents(toURL("blob:/path/to/file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "abcdef", contents)
+
+	stat, err := client.StatFile(toURL("blob:/path/to/file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, true, stat.Exists)
+	assert.Equal(t, "path/to/", stat.Path)
+	assert.Equal(t, "file.txt", stat.Name)
+	assert.Equal(t, "text/plain", stat.MimeType)
+	assert.Equal(t, 6, stat.SizeBytes)
+	assert.Equal(t, "\"e80b5017098950fc58aad83c8c14978e\"", stat.ETag)
+	assert.False(t, stat.LastModified.IsZero())
+
+	err = client.DeleteFile(toURL("blob:/path/to/file.txt"))
+	assert.Nil(t, err)
+
+	stat, err = client.StatFile(toURL("blob:/path/to/file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, false, stat.Exists)
+
+	err = client.DeleteFile(toURL("blob:/path/to/file.txt"))
+	assert.True(t, errors.Is(err, blob.ErrNotFound))
+}
+
+func TestServerMetadata(t *testing.T) {
+	server, client := testServerAndClient(TestReadAcl, TestWriteAcl)
+	defer server.Close()
+
+	request, err := http.NewRequest("POST", server.URL+"/file.txt", strings.NewReader("abc"))
+	assert.Nil(t, err)
+	request.Header.Set(credential_provider.HttpRequestWriteAclHeader, TestWriteAcl)
+	request.Header.Set("X-BlobStore-Meta-Owner", "someone")
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, 200, response.StatusCode)
+
+	stat, err := client.StatFile(toURL("blob:/file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"Owner": "someone"}, stat.Metadata)
+}
+
+func TestServerList(t *testing.T) {
+	server, client := testServerAndClient(TestReadAcl, TestWriteAcl)
+	defer server.Close()
+
+	server.Put("a/b/c.txt", []byte("c"), "")
+	server.Put("a/b/d/e.txt", []byte("e"), "")
+	server.Put("a/f.txt", []byte("f"), "")
+	server.Put("g.txt", []byte("g"), "")
+
+	paths, err := client.ListPrefix("", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a/", "g.txt"}, paths)
+
+	paths, err = client.ListPrefix("/a", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a/b/", "a/f.txt"}, paths)
+
+	paths, err = client.ListPrefix("a/", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a/b/c.txt", "a/b/d/e.txt", "a/f.txt"}, paths)
+
+	paths, err = client.ListPrefix("missing", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, paths)
+}
+
+func TestServerListStat(t *testing.T) {
+	server, client := testServerAndClient(TestReadAcl, TestWriteAcl)
+	defer server.Close()
+
+	server.Put("a/b/c.txt", []byte("c"), "")
+	server.Put("a/f.txt", []byte("ffff"), "text/plain")
+
+	stats, err := client.ListPrefixStat("a", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stats))
+
+	assert.Equal(t, "a/", stats[0].Path)
+	assert.Equal(t, "b/", stats[0].Name)
+	assert.Equal(t, 0, stats[0].SizeBytes)
+
+	object, _ := server.Get("a/f.txt")
+	assert.Equal(t, "a/", stats[1].Path)
+	assert.Equal(t, "f.txt", stats[1].Name)
+	assert.Equal(t, 4, stats[1].SizeBytes)
+	assert.Equal(t, "text/plain", stats[1].MimeType)
+	assert.Equal(t, object.ETag, stats[1].ETag)
+	assert.True(t, object.ModTime.Equal(stats[1].LastModified))
+}
+
+func TestServerRange(t *testing.T) {
+	server, client := testServerAndClient(TestReadAcl, TestWriteAcl)
+	defer server.Close()
+
+	server.Put("file.txt", []byte("abcdefghij"), "text/plain")
+
+	reader, err := client.Open(toURL("blob:/file.txt"))
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	buffer := make([]byte, 4)
+	n, err := reader.ReadAt(buffer, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "defg", string(buffer[:n]))
+
+	_, err = reader.Seek(-2, 2)
+	assert.Nil(t, err)
+
+	rest, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "ij", string(rest))
+}
+
+func TestServerEnforcesAcls(t *testing.T) {
+	server, client := testServerAndClient("", "")
+	defer server.Close()
+
+	server.Put("file.txt", []byte("abc"), "text/plain")
+
+	_, err := client.GetFileContents(toURL("blob:/file.txt"))
+	assert.True(t, errors.Is(err, blob.ErrForbidden))
+
+	_, err = client.ListPrefix("", false)
+	assert.True(t, errors.Is(err, blob.ErrForbidden))
+
+	err = client.DeleteFile(toURL("blob:/file.txt"))
+	assert.True(t, errors.Is(err, blob.ErrForbidden))
+
+	err = client.UploadFile(toURL("blob:/other.txt"), "server.go", "")
+	assert.True(t, errors.Is(err, blob.ErrForbidden))
+
+	var httpError *blob.BlobStoreHttpError
+	assert.True(t, errors.As(err, &httpError))
+	assert.NotEqual(t, "", httpError.RequestId)
+
+	_, exists := server.Get("other.txt")
+	assert.False(t, exists)
+}
+
+func TestServerSignedRequests(t *testing.T) {
+	server := NewServer(Config{
+		ReadAcl:     TestReadAcl,
+		WriteAcl:    TestWriteAcl,
+		SigningKeys: map[string]string{"key": "secret"},
+	})
+	defer server.Close()
+
+	client := blob.NewBlobStoreClient(server.URL, &credential_provider.SigningCredentialProvider{
+		KeyId:  "key",
+		Secret: "secret",
+	})
+
+	err := client.UploadFile(toURL("blob:/file.txt"), "server.go", "text/plain")
+	assert.Nil(t, err)
+
+	object, exists := server.Get("file.txt")
+	assert.True(t, exists)
+
+	contents, err := ioutil.ReadFile("server.go")
+	assert.Nil(t, err)
+	assert.Equal(t, contents, object.Contents)
+
+	fetched, err := client.GetFileContents(toURL("blob:/file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(contents), fetched)
+
+	// Copies stream the body from one request into the next, and it still has
+	// to be covered by the signature.
+	assert.Nil(t, client.Copy(toURL("blob:/file.txt"), toURL("blob:/copied.txt"), false))
+
+	object, exists = server.Get("copied.txt")
+	assert.True(t, exists)
+	assert.Equal(t, contents, object.Contents)
+
+	badClient := blob.NewBlobStoreClient(server.URL, &credential_provider.SigningCredentialProvider{
+		KeyId:  "key",
+		Secret: "wrong",
+	})
+
+	_, err = badClient.GetFileContents(toURL("blob:/file.txt"))
+	assert.True(t, errors.Is(err, blob.ErrForbidden))
+}
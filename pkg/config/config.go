@@ -0,0 +1,332 @@
+// Package config loads the blob CLI's config file, which holds named
+// profiles of settings like:
+//
+//	[default]
+//	endpoint = https://blob.example.com
+//
+//	[staging]
+//	endpoint = https://blob.staging.example.com
+//	credentials = exec
+//	credentials_command = vault-blob-credentials --team storage
+//	timeout = 1m
+//	retries = 5
+//	credentials.team-a/ = team-a
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/ini"
+)
+
+const (
+	ConfigDirectoryName = "blob"
+	ConfigFileName      = "config"
+
+	XdgConfigHomeEnvironmentVariable = "XDG_CONFIG_HOME"
+	ProfileEnvironmentVariable       = "BLOBSTORE_PROFILE"
+
+	DefaultProfileName = "default"
+)
+
+const (
+	EndpointKey    = "endpoint"
+	CredentialsKey = "credentials"
+	TimeoutKey     = "timeout"
+	RetriesKey     = "retries"
+
+	// Split on whitespace, without any shell quoting.
+	CredentialsCommandKey = "credentials_command"
+
+	// Keys like credentials.team-a/ name another profile, whose credentials
+	// are used for blobs under that prefix.
+	PrefixCredentialsKeyPrefix = "credentials."
+)
+
+var ErrProfileNotFound = errors.New("Profile not found")
+
+var Keys []string = []string{EndpointKey, CredentialsKey, CredentialsCommandKey, TimeoutKey, RetriesKey}
+
+// Where a profile's ACLs come from.
+// The default source tries everything the client knows about in turn.
+const (
+	CredentialsDefault     = "default"
+	CredentialsEnvironment = "env"
+	CredentialsFile        = "file"
+	CredentialsExec        = "exec"
+	CredentialsNone        = "none"
+)
+
+var CredentialsSources []string = []string{CredentialsDefault, CredentialsEnvironment, CredentialsFile, CredentialsExec, CredentialsNone}
+
+type Profile struct {
+	Name        string
+	Endpoint    string
+	Credentials string
+
+	// Only used by exec credentials.
+	CredentialsCommand []string
+
+	// Maps blob prefixes to the profiles whose credentials they use.
+	PrefixCredentials map[string]string
+
+	// Zero when the profile doesn't set a timeout.
+	Timeout time.Duration
+
+	// Nil when the profile doesn't set a number of retries.
+	Retries *int
+}
+
+type ProfileNotFoundError struct {
+	Profile string
+	Path    string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf("Profile %s not found in %s", e.Profile, e.Path)
+}
+
+func (e *ProfileNotFoundError) Unwrap() error {
+	return ErrProfileNotFound
+}
+
+type Config struct {
+	path string
+	file *ini.File
+}
+
+// $XDG_CONFIG_HOME/blob, falling back to ~/.config/blob.
+func DefaultConfigDirectory() (string, error) {
+	if configHome := os.Getenv(XdgConfigHomeEnvironmentVariable); configHome != "" {
+		return filepath.Join(configHome, ConfigDirectoryName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", ConfigDirectoryName), nil
+}
+
+func DefaultConfigPath() (string, error) {
+	dir, err := DefaultConfigDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, ConfigFileName), nil
+}
+
+// The profile named by the --profile flag, then $BLOBSTORE_PROFILE, then the
+// default profile.
+func SelectProfileName(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	if envValue := os.Getenv(ProfileEnvironmentVariable); envValue != "" {
+		return envValue
+	}
+
+	return DefaultProfileName
+}
+
+// A missing config file is treated like an empty one.
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Config{path, ini.NewFile()}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	iniFile, err := ini.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %s", path, err.Error())
+	}
+
+	return &Config{path, iniFile}, nil
+}
+
+func (c *Config) Path() string {
+	return c.path
+}
+
+func (c *Config) ProfileNames() []string {
+	rv := []string{}
+	for _, section := range c.file.Sections() {
+		rv = append(rv, section.Name)
+	}
+	return rv
+}
+
+// Only the default profile is allowed to be missing from the file, so that
+// the CLI works without any configuration.
+func (c *Config) Profile(name string) (*Profile, error) {
+	profile := &Profile{Name: name}
+
+	section, ok := c.file.Section(name)
+	if !ok {
+		if name == DefaultProfileName {
+			return profile, nil
+		}
+		return nil, &ProfileNotFoundError{name, c.path}
+	}
+
+	for _, key := range section.Keys() {
+		value, _ := section.Get(key)
+		if err := validateValue(key, value); err != nil {
+			return nil, fmt.Errorf("Invalid %s in profile %s: %s", key, name, err.Error())
+		}
+
+		switch key {
+		case EndpointKey:
+			profile.Endpoint = value
+		case CredentialsKey:
+			profile.Credentials = value
+		case CredentialsCommandKey:
+			profile.CredentialsCommand = strings.Fields(value)
+		case TimeoutKey:
+			profile.Timeout, _ = time.ParseDuration(value)
+		case RetriesKey:
+			retries, _ := strconv.Atoi(value)
+			profile.Retries = &retries
+		default:
+			if prefix, ok := prefixCredentialsPrefix(key); ok {
+				if profile.PrefixCredentials == nil {
+					profile.PrefixCredentials = map[string]string{}
+				}
+				profile.PrefixCredentials[prefix] = value
+			}
+		}
+	}
+
+	if profile.Credentials == CredentialsExec && len(profile.CredentialsCommand) == 0 {
+		return nil, fmt.Errorf("Profile %s uses %s credentials, but doesn't set %s", name, CredentialsExec, CredentialsCommandKey)
+	}
+
+	return profile, nil
+}
+
+// Returns the keys set in the profile, in the order they appear in the file.
+func (c *Config) List(profile string) []string {
+	section, ok := c.file.Section(profile)
+	if !ok {
+		return []string{}
+	}
+
+	return section.Keys()
+}
+
+func (c *Config) Get(profile, key string) (string, bool) {
+	section, ok := c.file.Section(profile)
+	if !ok {
+		return "", false
+	}
+
+	return section.Get(key)
+}
+
+func (c *Config) Set(profile, key, value string) error {
+	if !isKnownKey(key) {
+		return fmt.Errorf("Unknown config key %s; must be one of %s, or %s<prefix>", key, strings.Join(Keys, ", "), PrefixCredentialsKeyPrefix)
+	}
+
+	if err := validateValue(key, value); err != nil {
+		return err
+	}
+
+	c.file.AddSection(profile).Set(key, value)
+	return nil
+}
+
+func (c *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+
+	var contents strings.Builder
+	if _, err := c.file.WriteTo(&contents); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path, []byte(contents.String()), 0600)
+}
+
+func prefixCredentialsPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, PrefixCredentialsKeyPrefix) || len(key) == len(PrefixCredentialsKeyPrefix) {
+		return "", false
+	}
+
+	return key[len(PrefixCredentialsKeyPrefix):], true
+}
+
+func isKnownKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+
+	_, ok := prefixCredentialsPrefix(key)
+	return ok
+}
+
+func validateValue(key, value string) error {
+	switch key {
+	case EndpointKey:
+		endpoint, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return fmt.Errorf("Endpoint %s must be an http or https URL", value)
+		}
+	case CredentialsKey:
+		for _, source := range CredentialsSources {
+			if source == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("Unknown credentials source %s; must be one of %s", value, strings.Join(CredentialsSources, ", "))
+	case TimeoutKey:
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("Timeout %s must be positive", value)
+		}
+	case CredentialsCommandKey:
+		if len(strings.Fields(value)) == 0 {
+			return errors.New("Credentials command cannot be empty")
+		}
+	case RetriesKey:
+		retries, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if retries < 0 {
+			return errors.New("Cannot retry a negative number of times")
+		}
+	default:
+		if _, ok := prefixCredentialsPrefix(key); ok && value == "" {
+			return errors.New("Prefix credentials must name a profile")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestConfig(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+
+	path := filepath.Join(dir, ConfigFileName)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600))
+
+	return path
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	original, ok := os.LookupEnv(XdgConfigHomeEnvironmentVariable)
+	if ok {
+		defer os.Setenv(XdgConfigHomeEnvironmentVariable, original)
+	} else {
+		defer os.Unsetenv(XdgConfigHomeEnvironmentVariable)
+	}
+
+	os.Setenv(XdgConfigHomeEnvironmentVariable, "/some/where")
+
+	path, err := DefaultConfigPath()
+	assert.Nil(t, err)
+	assert.Equal(t, "/some/where/blob/config", path)
+}
+
+func TestSelectProfileName(t *testing.T) {
+	original, ok := os.LookupEnv(ProfileEnvironmentVariable)
+	if ok {
+		defer os.Setenv(ProfileEnvironmentVariable, original)
+	} else {
+		defer os.Unsetenv(ProfileEnvironmentVariable)
+	}
+
+	os.Unsetenv(ProfileEnvironmentVariable)
+	assert.Equal(t, DefaultProfileName, SelectProfileName(""))
+
+	os.Setenv(ProfileEnvironmentVariable, "staging")
+	assert.Equal(t, "staging", SelectProfileName(""))
+	assert.Equal(t, "production", SelectProfileName("production"))
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	config, err := Load("/does/not/exist/config")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, config.ProfileNames())
+
+	profile, err := config.Profile(DefaultProfileName)
+	assert.Nil(t, err)
+	assert.Equal(t, &Profile{Name: DefaultProfileName}, profile)
+
+	_, err = config.Profile("staging")
+	assert.Equal(t, "Profile staging not found in /does/not/exist/config", err.Error())
+	assert.True(t, errors.Is(err, ErrProfileNotFound))
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := writeTestConfig(t, `
+[default]
+endpoint = https://blob.example.com
+
+[staging]
+endpoint = https://blob.staging.example.com
+credentials = exec
+credentials_command =  vault-blob-credentials  --team storage
+timeout = 1m
+retries = 0
+credentials.team-a/ = team-a
+credentials.team-b = team-b
+unknown = ignored
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	config, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"default", "staging"}, config.ProfileNames())
+
+	profile, err := config.Profile("default")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://blob.example.com", profile.Endpoint)
+	assert.Equal(t, "", profile.Credentials)
+	assert.Equal(t, time.Duration(0), profile.Timeout)
+	assert.Nil(t, profile.Retries)
+
+	profile, err = config.Profile("staging")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://blob.staging.example.com", profile.Endpoint)
+	assert.Equal(t, CredentialsExec, profile.Credentials)
+	assert.Equal(t, []string{"vault-blob-credentials", "--team", "storage"}, profile.CredentialsCommand)
+	assert.Equal(t, time.Minute, profile.Timeout)
+	assert.Equal(t, 0, *profile.Retries)
+	assert.Equal(t, map[string]string{"team-a/": "team-a", "team-b": "team-b"}, profile.PrefixCredentials)
+}
+
+func TestLoadInvalidProfile(t *testing.T) {
+	path := writeTestConfig(t, "[default]\ntimeout = soon\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	config, err := Load(path)
+	assert.Nil(t, err)
+
+	_, err = config.Profile("default")
+	assert.Equal(t, `Invalid timeout in profile default: time: invalid duration "soon"`, err.Error())
+
+	path = writeTestConfig(t, "[default]\ncredentials = exec\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	config, err = Load(path)
+	assert.Nil(t, err)
+
+	_, err = config.Profile("default")
+	assert.Equal(t, "Profile default uses exec credentials, but doesn't set credentials_command", err.Error())
+}
+
+func TestSetAndSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nested", ConfigFileName)
+	config, err := Load(path)
+	assert.Nil(t, err)
+
+	assert.Nil(t, config.Set("staging", EndpointKey, "http://localhost:8080"))
+	assert.Nil(t, config.Set("staging", RetriesKey, "2"))
+	assert.Nil(t, config.Set("staging", "credentials.team-a/", "team-a"))
+
+	err = config.Set("staging", "credentials.", "team-a")
+	assert.Equal(t, "Unknown config key credentials.; must be one of endpoint, credentials, credentials_command, timeout, retries, or credentials.<prefix>", err.Error())
+
+	err = config.Set("staging", "credentials.team-b/", "")
+	assert.Equal(t, "Prefix credentials must name a profile", err.Error())
+
+	err = config.Set("staging", "colour", "blue")
+	assert.Equal(t, "Unknown config key colour; must be one of endpoint, credentials, credentials_command, timeout, retries, or credentials.<prefix>", err.Error())
+
+	err = config.Set("staging", EndpointKey, "ftp://localhost")
+	assert.Equal(t, "Endpoint ftp://localhost must be an http or https URL", err.Error())
+
+	err = config.Set("staging", CredentialsKey, "magic")
+	assert.Equal(t, "Unknown credentials source magic; must be one of default, env, file, exec, none", err.Error())
+
+	err = config.Set("staging", RetriesKey, "-1")
+	assert.Equal(t, "Cannot retry a negative number of times", err.Error())
+
+	err = config.Set("staging", TimeoutKey, "0s")
+	assert.Equal(t, "Timeout 0s must be positive", err.Error())
+
+	assert.Nil(t, config.Save())
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	reloaded, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{EndpointKey, RetriesKey, "credentials.team-a/"}, reloaded.List("staging"))
+	assert.Equal(t, []string{}, reloaded.List("default"))
+
+	value, ok := reloaded.Get("staging", EndpointKey)
+	assert.True(t, ok)
+	assert.Equal(t, "http://localhost:8080", value)
+
+	_, ok = reloaded.Get("staging", TimeoutKey)
+	assert.False(t, ok)
+}
@@ -29,33 +29,78 @@ func HasWriteAclHeader(request *http.Request) bool {
 	return ok
 }
 
-func DefaultCredentialProviderChain() *CredentialProviderChain {
-	if defaultPc != nil {
-		return defaultPc
-	}
-
-	defaultPc = new(CredentialProviderChain)
+func NewCredentialProviderChain(providers ...ICredentialProvider) *CredentialProviderChain {
+	return &CredentialProviderChain{providers}
+}
 
-	defaultPc.providers = append(defaultPc.providers,
+// The environment, then the given profile of the credentials file. Empty
+// values use the default credentials file and profile.
+func NewDefaultCredentialProviderChain(credentialsPath, profile string) *CredentialProviderChain {
+	return NewCredentialProviderChain(
 		&EnvironmentCredentialProvider{
 			DefaultBlobStoreReadAclEnvironmentVariable,
 			DefaultBlobStoreWriteAclEnvironmentVariable,
 		},
+		&FileCredentialProvider{Path: credentialsPath, Profile: profile},
 		&DirectCredentialProvider{"", ""},
 	)
+}
+
+// Reads only need the read ACL, and anything that changes the blobstore only
+// needs the write ACL, so neither token is sent where it isn't needed.
+func RequiresReadAcl(request *http.Request) bool {
+	return request.Method == "GET" || request.Method == "HEAD" || request.Method == ""
+}
+
+func RequiresWriteAcl(request *http.Request) bool {
+	return !RequiresReadAcl(request)
+}
+
+func NeedsReadAcl(request *http.Request) bool {
+	return RequiresReadAcl(request) && !HasReadAclHeader(request)
+}
+
+func NeedsWriteAcl(request *http.Request) bool {
+	return RequiresWriteAcl(request) && !HasWriteAclHeader(request)
+}
+
+func HasRequiredAclHeaders(request *http.Request) bool {
+	return !NeedsReadAcl(request) && !NeedsWriteAcl(request)
+}
+
+func DefaultCredentialProviderChain() *CredentialProviderChain {
+	if defaultPc != nil {
+		return defaultPc
+	}
+
+	defaultPc = NewDefaultCredentialProviderChain("", "")
 	return defaultPc
 }
 
 func (cpc *CredentialProviderChain) AuthorizeRequest(request *http.Request) error {
+	_, err := cpc.AuthorizeRequestWithProvenance(request)
+	return err
+}
+
+// Same as AuthorizeRequest, but also reports which provider in the chain
+// supplied each header.
+func (cpc *CredentialProviderChain) AuthorizeRequestWithProvenance(request *http.Request) ([]CredentialSource, error) {
+	sources := []CredentialSource{}
 	for _, provider := range cpc.providers {
-		if err := provider.AuthorizeRequest(request); err != nil {
-			return err
+		providerSources, err := ExplainAuthorization(provider, request)
+		sources = append(sources, providerSources...)
+		if err != nil {
+			return sources, err
 		}
-		if HasReadAclHeader(request) && HasWriteAclHeader(request) {
-			return nil
+		if HasRequiredAclHeaders(request) {
+			return sources, nil
 		}
 	}
 
 	// Maybe no authorization is desired.
-	return nil
+	return sources, nil
+}
+
+func (cpc *CredentialProviderChain) String() string {
+	return "credential provider chain"
 }
@@ -13,9 +13,18 @@ import (
 func TestDefaultProviderChainDefaultProviders(t *testing.T) {
 	dcpc := DefaultCredentialProviderChain()
 
-	assert.Equal(t, 2, len(dcpc.providers))
+	assert.Equal(t, 3, len(dcpc.providers))
 	assert.IsType(t, &EnvironmentCredentialProvider{}, dcpc.providers[0])
-	assert.IsType(t, &DirectCredentialProvider{}, dcpc.providers[1])
+	assert.IsType(t, &FileCredentialProvider{}, dcpc.providers[1])
+	assert.IsType(t, &DirectCredentialProvider{}, dcpc.providers[2])
+}
+
+func TestNewDefaultProviderChainProfile(t *testing.T) {
+	dcpc := NewDefaultCredentialProviderChain("/some/credentials", "staging")
+
+	assert.Equal(t, 3, len(dcpc.providers))
+	assert.Equal(t, "/some/credentials", dcpc.providers[1].(*FileCredentialProvider).Path)
+	assert.Equal(t, "staging", dcpc.providers[1].(*FileCredentialProvider).Profile)
 }
 
 func TestDefaultProviderChainDefaultProviderBehaviour(t *testing.T) {
@@ -35,5 +44,46 @@ func TestDefaultProviderChainDefaultProviderBehaviour(t *testing.T) {
 	err = dcpc.AuthorizeRequest(request)
 	assert.Nil(t, err)
 	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+
+	request, err = http.NewRequest("POST", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	err = dcpc.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.False(t, HasReadAclHeader(request))
 	assert.Equal(t, "bcd", request.Header.Get("X-BlobStore-Write-Acl"))
 }
+
+func TestProviderChainStopsWhenRequiredHeadersPresent(t *testing.T) {
+	chain := NewCredentialProviderChain(
+		&ExecCredentialProvider{},
+	)
+
+	// Headers it doesn't need don't count.
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+	request.Header.Add(HttpRequestWriteAclHeader, "bcd")
+	assert.Equal(t, "No credential command configured", chain.AuthorizeRequest(request).Error())
+
+	request, err = http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+	request.Header.Add(HttpRequestReadAclHeader, "abc")
+	assert.Nil(t, chain.AuthorizeRequest(request))
+}
+
+func TestRequiredAcls(t *testing.T) {
+	for _, method := range []string{"GET", "HEAD"} {
+		request, err := http.NewRequest(method, "https://example.org", nil)
+		assert.Nil(t, err)
+		assert.True(t, RequiresReadAcl(request))
+		assert.False(t, RequiresWriteAcl(request))
+	}
+
+	for _, method := range []string{"POST", "DELETE"} {
+		request, err := http.NewRequest(method, "https://example.org", nil)
+		assert.Nil(t, err)
+		assert.False(t, RequiresReadAcl(request))
+		assert.True(t, RequiresWriteAcl(request))
+	}
+}
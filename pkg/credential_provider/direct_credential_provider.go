@@ -10,11 +10,15 @@ type DirectCredentialProvider struct {
 }
 
 func (dcp *DirectCredentialProvider) AuthorizeRequest(request *http.Request) error {
-	if !HasReadAclHeader(request) {
+	if NeedsReadAcl(request) {
 		request.Header.Add(HttpRequestReadAclHeader, dcp.ReadAcl)
 	}
-	if !HasWriteAclHeader(request) {
+	if NeedsWriteAcl(request) {
 		request.Header.Add(HttpRequestWriteAclHeader, dcp.WriteAcl)
 	}
 	return nil
 }
+
+func (dcp *DirectCredentialProvider) String() string {
+	return "direct credentials"
+}
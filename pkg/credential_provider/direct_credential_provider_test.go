@@ -18,11 +18,19 @@ func TestDirectCredentialProvider(t *testing.T) {
 	err = dcp.AuthorizeRequest(request)
 	assert.Nil(t, err)
 	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
-	assert.Equal(t, "bcd", request.Header.Get("X-BlobStore-Write-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
 
 	dcp2 := DirectCredentialProvider{"cde", "def"}
 	err = dcp2.AuthorizeRequest(request)
 	assert.Nil(t, err)
 	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+
+	request, err = http.NewRequest("POST", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	err = dcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.False(t, HasReadAclHeader(request))
 	assert.Equal(t, "bcd", request.Header.Get("X-BlobStore-Write-Acl"))
 }
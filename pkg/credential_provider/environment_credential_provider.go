@@ -1,6 +1,7 @@
 package credential_provider
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 )
@@ -11,15 +12,19 @@ type EnvironmentCredentialProvider struct {
 }
 
 func (ecp *EnvironmentCredentialProvider) AuthorizeRequest(request *http.Request) error {
-	if !HasReadAclHeader(request) {
+	if NeedsReadAcl(request) {
 		if acl, ok := os.LookupEnv(ecp.ReadAclEnvironmentVariable); ok {
 			request.Header.Add(HttpRequestReadAclHeader, acl)
 		}
 	}
-	if !HasWriteAclHeader(request) {
+	if NeedsWriteAcl(request) {
 		if acl, ok := os.LookupEnv(ecp.WriteAclEnvironmentVariable); ok {
 			request.Header.Add(HttpRequestWriteAclHeader, acl)
 		}
 	}
 	return nil
 }
+
+func (ecp *EnvironmentCredentialProvider) String() string {
+	return fmt.Sprintf("environment variables %s and %s", ecp.ReadAclEnvironmentVariable, ecp.WriteAclEnvironmentVariable)
+}
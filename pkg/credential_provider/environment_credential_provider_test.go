@@ -31,11 +31,19 @@ func TestEnvironmentCredentialProvider(t *testing.T) {
 	err = ecp.AuthorizeRequest(request)
 	assert.Nil(t, err)
 	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
-	assert.Equal(t, "bcd", request.Header.Get("X-BlobStore-Write-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
 
 	ecp2 := EnvironmentCredentialProvider{"TEST_READ_ACL_IGNORED", "TEST_WRITE_ACL_IGNORED"}
 	err = ecp2.AuthorizeRequest(request)
 	assert.Nil(t, err)
 	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+
+	request, err = http.NewRequest("DELETE", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	err = ecp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.False(t, HasReadAclHeader(request))
 	assert.Equal(t, "bcd", request.Header.Get("X-BlobStore-Write-Acl"))
 }
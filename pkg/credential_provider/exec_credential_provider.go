@@ -0,0 +1,138 @@
+package credential_provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ExecCredentialVersion = 1
+
+	DefaultExecCredentialRefreshWindow = time.Minute
+)
+
+// The document an ExecCredentialProvider command prints to stdout:
+//
+//	{
+//	  "version": 1,
+//	  "read_acl": "...",
+//	  "write_acl": "...",
+//	  "expiration": "2026-01-02T15:04:05Z"
+//	}
+//
+// Either ACL may be left out, in which case the rest of the credential
+// provider chain gets a chance to supply it. The expiration is an RFC 3339
+// timestamp; without one, credentials are kept for as long as the process
+// runs.
+type ExecCredentials struct {
+	Version    int        `json:"version"`
+	ReadAcl    string     `json:"read_acl,omitempty"`
+	WriteAcl   string     `json:"write_acl,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// Gets ACLs from an external command, so that they never have to be stored
+// in the environment or on disk.
+//
+// The command inherits stdin and stderr, so it can prompt if it needs to,
+// and must exit 0 after printing an ExecCredentials document to stdout.
+// The command is only run again once its credentials are within
+// RefreshWindow of expiring; concurrent requests wait on a single run.
+type ExecCredentialProvider struct {
+	Command       []string
+	RefreshWindow time.Duration
+
+	lock        sync.Mutex
+	credentials *ExecCredentials
+}
+
+func NewExecCredentialProvider(command ...string) *ExecCredentialProvider {
+	return &ExecCredentialProvider{
+		Command:       command,
+		RefreshWindow: DefaultExecCredentialRefreshWindow,
+	}
+}
+
+func (ecp *ExecCredentialProvider) AuthorizeRequest(request *http.Request) error {
+	if HasRequiredAclHeaders(request) {
+		return nil
+	}
+
+	credentials, err := ecp.getCredentials(request)
+	if err != nil {
+		return err
+	}
+
+	if NeedsReadAcl(request) && credentials.ReadAcl != "" {
+		request.Header.Add(HttpRequestReadAclHeader, credentials.ReadAcl)
+	}
+	if NeedsWriteAcl(request) && credentials.WriteAcl != "" {
+		request.Header.Add(HttpRequestWriteAclHeader, credentials.WriteAcl)
+	}
+	return nil
+}
+
+func (ecp *ExecCredentialProvider) getCredentials(request *http.Request) (*ExecCredentials, error) {
+	ecp.lock.Lock()
+	defer ecp.lock.Unlock()
+
+	if ecp.credentials != nil {
+		expiration := ecp.credentials.Expiration
+		if expiration == nil || time.Now().Add(ecp.RefreshWindow).Before(*expiration) {
+			return ecp.credentials, nil
+		}
+	}
+
+	credentials, err := ecp.runCommand(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ecp.credentials = credentials
+	return credentials, nil
+}
+
+func (ecp *ExecCredentialProvider) runCommand(request *http.Request) (*ExecCredentials, error) {
+	if len(ecp.Command) == 0 {
+		return nil, errors.New("No credential command configured")
+	}
+
+	commandString := strings.Join(ecp.Command, " ")
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(request.Context(), ecp.Command[0], ecp.Command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Credential command %s failed: %s", commandString, err.Error())
+	}
+
+	var credentials ExecCredentials
+	if err := json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return nil, fmt.Errorf("Credential command %s printed invalid credentials: %s", commandString, err.Error())
+	}
+
+	if credentials.Version != ExecCredentialVersion {
+		return nil, fmt.Errorf("Credential command %s printed unsupported version %d; expected %d", commandString, credentials.Version, ExecCredentialVersion)
+	}
+
+	if credentials.Expiration != nil && !time.Now().Before(*credentials.Expiration) {
+		return nil, fmt.Errorf("Credential command %s printed credentials that have already expired", commandString)
+	}
+
+	return &credentials, nil
+}
+
+func (ecp *ExecCredentialProvider) String() string {
+	return fmt.Sprintf("credential command %s", strings.Join(ecp.Command, " "))
+}
@@ -0,0 +1,146 @@
+package credential_provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes a script that prints the given output, and counts how many times
+// it has been run.
+func writeCredentialScript(t *testing.T, output string) (string, func() int) {
+	dir, err := ioutil.TempDir("", "exec-credentials")
+	assert.Nil(t, err)
+
+	countPath := filepath.Join(dir, "count")
+	scriptPath := filepath.Join(dir, "credentials.sh")
+	script := fmt.Sprintf("#!/bin/sh\necho run >> %s\ncat <<'EOF'\n%s\nEOF\n", countPath, output)
+	assert.Nil(t, ioutil.WriteFile(scriptPath, []byte(script), 0700))
+
+	count := func() int {
+		contents, err := ioutil.ReadFile(countPath)
+		if err != nil {
+			return 0
+		}
+		return strings.Count(string(contents), "run")
+	}
+
+	return scriptPath, count
+}
+
+func authorizeTestRequest(t *testing.T, provider ICredentialProvider) (*http.Request, error) {
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	return request, provider.AuthorizeRequest(request)
+}
+
+func TestExecCredentialProvider(t *testing.T) {
+	script, count := writeCredentialScript(t, `{"version": 1, "read_acl": "abc", "write_acl": "bcd"}`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	ecp := NewExecCredentialProvider(script)
+
+	request, err := authorizeTestRequest(t, ecp)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+
+	request, err = authorizeTestRequest(t, ecp)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+
+	assert.Equal(t, 1, count())
+}
+
+func TestExecCredentialProviderPartial(t *testing.T) {
+	script, _ := writeCredentialScript(t, `{"version": 1, "read_acl": "abc"}`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	request, err := authorizeTestRequest(t, NewExecCredentialProvider(script))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+}
+
+func TestExecCredentialProviderRefreshes(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	script, count := writeCredentialScript(t, `{"version": 1, "read_acl": "abc", "expiration": "`+expiration+`"}`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	ecp := NewExecCredentialProvider(script)
+
+	_, err := authorizeTestRequest(t, ecp)
+	assert.Nil(t, err)
+	_, err = authorizeTestRequest(t, ecp)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count())
+
+	// Pretend the credentials are about to expire.
+	ecp.RefreshWindow = 2 * time.Hour
+
+	_, err = authorizeTestRequest(t, ecp)
+	assert.Nil(t, err)
+	_, err = authorizeTestRequest(t, ecp)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count())
+}
+
+func TestExecCredentialProviderConcurrent(t *testing.T) {
+	script, count := writeCredentialScript(t, `{"version": 1, "read_acl": "abc", "write_acl": "bcd"}`)
+	defer os.RemoveAll(filepath.Dir(script))
+
+	ecp := NewExecCredentialProvider(script)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			request, err := authorizeTestRequest(t, ecp)
+			assert.Nil(t, err)
+			assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, count())
+}
+
+func TestExecCredentialProviderFailures(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		output   string
+		expected string
+	}{
+		{`not json`, "printed invalid credentials: invalid character 'o' in literal null (expecting 'u')"},
+		{`{"read_acl": "abc"}`, "printed unsupported version 0; expected 1"},
+		{`{"version": 1, "expiration": "` + expired + `"}`, "printed credentials that have already expired"},
+	}
+
+	for _, c := range cases {
+		script, _ := writeCredentialScript(t, c.output)
+		defer os.RemoveAll(filepath.Dir(script))
+
+		_, err := authorizeTestRequest(t, NewExecCredentialProvider(script))
+		assert.Equal(t, "Credential command "+script+" "+c.expected, err.Error())
+	}
+
+	_, err := authorizeTestRequest(t, NewExecCredentialProvider("false"))
+	assert.Equal(t, "Credential command false failed: exit status 1", err.Error())
+
+	_, err = authorizeTestRequest(t, NewExecCredentialProvider())
+	assert.Equal(t, "No credential command configured", err.Error())
+}
@@ -0,0 +1,196 @@
+package credential_provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/Eagerod/blobstore-client/pkg/ini"
+)
+
+const (
+	CredentialsFileName = "credentials"
+
+	CredentialsFileReadAclKey  = "read_acl"
+	CredentialsFileWriteAclKey = "write_acl"
+
+	DefaultCredentialsProfile = "default"
+)
+
+// Reads ACLs from an INI file with a section per profile:
+//
+//	[default]
+//	read_acl = ...
+//	write_acl = ...
+//
+// An empty Path uses DefaultCredentialsFilePath, and an empty Profile uses
+// the default profile.
+// A missing file or section provides nothing, but a file that other users
+// can read is refused outright.
+// The file is only parsed again once it changes.
+type FileCredentialProvider struct {
+	Path    string
+	Profile string
+
+	lock   sync.Mutex
+	cached *cachedCredentialsFile
+}
+
+type cachedCredentialsFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+	file    *ini.File
+}
+
+// $XDG_CONFIG_HOME/blob/credentials, falling back to ~/.config/blob/credentials.
+func DefaultCredentialsFilePath() (string, error) {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "blob", CredentialsFileName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "blob", CredentialsFileName), nil
+}
+
+func (fcp *FileCredentialProvider) path() (string, error) {
+	if fcp.Path != "" {
+		return fcp.Path, nil
+	}
+
+	return DefaultCredentialsFilePath()
+}
+
+func (fcp *FileCredentialProvider) profile() string {
+	if fcp.Profile != "" {
+		return fcp.Profile
+	}
+
+	return DefaultCredentialsProfile
+}
+
+func (fcp *FileCredentialProvider) AuthorizeRequest(request *http.Request) error {
+	if HasRequiredAclHeaders(request) {
+		return nil
+	}
+
+	path, err := fcp.path()
+	if err != nil {
+		return err
+	}
+
+	file, err := fcp.load(path)
+	if err != nil || file == nil {
+		return err
+	}
+
+	section, ok := file.Section(fcp.profile())
+	if !ok {
+		return nil
+	}
+
+	if NeedsReadAcl(request) {
+		if acl, ok := section.Get(CredentialsFileReadAclKey); ok {
+			request.Header.Add(HttpRequestReadAclHeader, acl)
+		}
+	}
+	if NeedsWriteAcl(request) {
+		if acl, ok := section.Get(CredentialsFileWriteAclKey); ok {
+			request.Header.Add(HttpRequestWriteAclHeader, acl)
+		}
+	}
+	return nil
+}
+
+// Returns the parsed file from the last request, unless the file has been
+// modified since.
+func (fcp *FileCredentialProvider) load(path string) (*ini.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	fcp.lock.Lock()
+	defer fcp.lock.Unlock()
+
+	cached := fcp.cached
+	if cached != nil && cached.path == path && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() && cached.mode == info.Mode() {
+		return cached.file, nil
+	}
+
+	file, info, err := loadCredentialsFile(path)
+	if err != nil || file == nil {
+		fcp.cached = nil
+		return nil, err
+	}
+
+	fcp.cached = &cachedCredentialsFile{path, info.ModTime(), info.Size(), info.Mode(), file}
+	return file, nil
+}
+
+func CredentialsFileHasProfile(path, profile string) (bool, error) {
+	section, err := loadCredentialsSection(path, profile)
+	return section != nil, err
+}
+
+func loadCredentialsSection(path, profile string) (*ini.Section, error) {
+	file, _, err := loadCredentialsFile(path)
+	if err != nil || file == nil {
+		return nil, err
+	}
+
+	section, ok := file.Section(profile)
+	if !ok {
+		return nil, nil
+	}
+
+	return section, nil
+}
+
+func loadCredentialsFile(path string) (*ini.File, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Unix permissions don't mean much on Windows.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, nil, fmt.Errorf("Credentials file %s is accessible by other users; run chmod 600 %s", path, path)
+	}
+
+	iniFile, err := ini.Parse(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse %s: %s", path, err.Error())
+	}
+
+	return iniFile, info, nil
+}
+
+func (fcp *FileCredentialProvider) String() string {
+	path, _ := fcp.path()
+	return fmt.Sprintf("credentials file %s (profile %s)", path, fcp.profile())
+}
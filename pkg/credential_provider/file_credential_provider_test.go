@@ -0,0 +1,196 @@
+package credential_provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+const testCredentialsFile string = `
+[default]
+read_acl = abc
+write_acl = bcd
+
+[staging]
+read_acl = cde
+`
+
+func writeTestCredentials(t *testing.T, mode os.FileMode) string {
+	dir, err := ioutil.TempDir("", "credentials")
+	assert.Nil(t, err)
+
+	path := filepath.Join(dir, CredentialsFileName)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(testCredentialsFile), mode))
+	assert.Nil(t, os.Chmod(path, mode))
+
+	return path
+}
+
+func TestFileCredentialProvider(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	fcp := FileCredentialProvider{Path: path, Profile: "default"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+}
+
+func TestFileCredentialProviderPartialProfile(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	fcp := FileCredentialProvider{Path: path, Profile: "staging"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "cde", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+}
+
+func TestFileCredentialProviderDoesNotOverwrite(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+	request.Header.Add(HttpRequestReadAclHeader, "zyx")
+
+	fcp := FileCredentialProvider{Path: path, Profile: "default"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "zyx", request.Header.Get("X-BlobStore-Read-Acl"))
+	assert.False(t, HasWriteAclHeader(request))
+}
+
+func TestFileCredentialProviderMissing(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	fcp := FileCredentialProvider{Path: path, Profile: "production"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.False(t, HasReadAclHeader(request))
+	assert.False(t, HasWriteAclHeader(request))
+
+	fcp = FileCredentialProvider{Path: filepath.Join(filepath.Dir(path), "missing"), Profile: "default"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.False(t, HasReadAclHeader(request))
+	assert.False(t, HasWriteAclHeader(request))
+}
+
+func TestFileCredentialProviderPermissive(t *testing.T) {
+	path := writeTestCredentials(t, 0644)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	fcp := FileCredentialProvider{Path: path, Profile: "default"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Equal(t, "Credentials file "+path+" is accessible by other users; run chmod 600 "+path, err.Error())
+	assert.False(t, HasReadAclHeader(request))
+}
+
+func TestFileCredentialProviderDefaultPath(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	dir := filepath.Dir(path)
+	defer os.RemoveAll(dir)
+
+	blobDir := filepath.Join(dir, "blob")
+	assert.Nil(t, os.Mkdir(blobDir, 0700))
+	assert.Nil(t, os.Rename(path, filepath.Join(blobDir, CredentialsFileName)))
+
+	original, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	if ok {
+		defer os.Setenv("XDG_CONFIG_HOME", original)
+	} else {
+		defer os.Unsetenv("XDG_CONFIG_HOME")
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	fcp := FileCredentialProvider{Profile: "staging"}
+	err = fcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "cde", request.Header.Get("X-BlobStore-Read-Acl"))
+}
+
+func TestFileCredentialProviderIgnoresProfileEnvironment(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	os.Setenv("BLOBSTORE_PROFILE", "staging")
+	defer os.Unsetenv("BLOBSTORE_PROFILE")
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	fcp := FileCredentialProvider{Path: path}
+	assert.Nil(t, fcp.AuthorizeRequest(request))
+	assert.Equal(t, "abc", request.Header.Get("X-BlobStore-Read-Acl"))
+}
+
+func TestFileCredentialProviderReloadsModifiedFile(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	fcp := FileCredentialProvider{Path: path, Profile: "staging"}
+	authorize := func() string {
+		request, err := http.NewRequest("GET", "https://example.org", nil)
+		assert.Nil(t, err)
+		assert.Nil(t, fcp.AuthorizeRequest(request))
+		return request.Header.Get("X-BlobStore-Read-Acl")
+	}
+
+	assert.Equal(t, "cde", authorize())
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	// Same size and modification time, so the cached file is still used.
+	assert.Nil(t, ioutil.WriteFile(path, []byte(strings.Replace(testCredentialsFile, "cde", "xyz", 1)), 0600))
+	assert.Nil(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+	assert.Equal(t, "cde", authorize())
+
+	later := info.ModTime().Add(time.Second)
+	assert.Nil(t, os.Chtimes(path, later, later))
+	assert.Equal(t, "xyz", authorize())
+}
+
+func TestCredentialsFileHasProfile(t *testing.T) {
+	path := writeTestCredentials(t, 0600)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	ok, err := CredentialsFileHasProfile(path, "staging")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = CredentialsFileHasProfile(path, "production")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = CredentialsFileHasProfile(filepath.Join(filepath.Dir(path), "missing"), "staging")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
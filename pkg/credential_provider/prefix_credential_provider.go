@@ -0,0 +1,78 @@
+package credential_provider
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const listPrefixPathComponent = "_dir/"
+
+// Picks credentials by the longest configured prefix of the blob being
+// requested, so that different parts of a blobstore can use different ACLs.
+// Prefixes only match whole path components, so "team-a" matches
+// "team-a/file" but not "team-ab/file".
+//
+// BasePath is the path of the blobstore's base URL, which is removed from
+// request paths before matching. Requests that don't match any prefix use
+// Default, if it's set.
+type PrefixCredentialProvider struct {
+	BasePath string
+	Prefixes map[string]ICredentialProvider
+	Default  ICredentialProvider
+}
+
+func (pcp *PrefixCredentialProvider) blobPath(request *http.Request) string {
+	path := strings.TrimPrefix(request.URL.Path, "/")
+	path = strings.TrimPrefix(path, strings.Trim(pcp.BasePath, "/")+"/")
+	return strings.TrimPrefix(path, listPrefixPathComponent)
+}
+
+// A prefix written with a trailing slash still matches the directory itself,
+// so that listing "team-a" uses the same credentials as "team-a/file".
+func prefixMatches(path, prefix string) bool {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if path == strings.TrimSuffix(prefix, "/") {
+		return true
+	}
+
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// Returns the provider that would authorize the request, and the prefix
+// that selected it, which is empty when falling back to the default.
+func (pcp *PrefixCredentialProvider) ProviderFor(request *http.Request) (ICredentialProvider, string) {
+	path := pcp.blobPath(request)
+
+	longest := ""
+	var provider ICredentialProvider
+	for prefix, p := range pcp.Prefixes {
+		if prefixMatches(path, prefix) && (provider == nil || len(prefix) > len(longest)) {
+			longest = prefix
+			provider = p
+		}
+	}
+
+	if provider == nil {
+		return pcp.Default, ""
+	}
+
+	return provider, longest
+}
+
+func (pcp *PrefixCredentialProvider) AuthorizeRequest(request *http.Request) error {
+	provider, _ := pcp.ProviderFor(request)
+	if provider == nil {
+		return nil
+	}
+
+	return provider.AuthorizeRequest(request)
+}
+
+func (pcp *PrefixCredentialProvider) String() string {
+	return fmt.Sprintf("credentials for %d prefixes", len(pcp.Prefixes))
+}
@@ -0,0 +1,91 @@
+package credential_provider
+
+import (
+	"net/http"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func testPrefixCredentialProvider() *PrefixCredentialProvider {
+	return &PrefixCredentialProvider{
+		BasePath: "/deeper/",
+		Prefixes: map[string]ICredentialProvider{
+			"team-a":           &DirectCredentialProvider{"a-read", "a-write"},
+			"/team-a/private/": &DirectCredentialProvider{"private-read", "private-write"},
+			"team-b/":          &DirectCredentialProvider{"b-read", "b-write"},
+		},
+		Default: &DirectCredentialProvider{"default-read", "default-write"},
+	}
+}
+
+func TestPrefixCredentialProvider(t *testing.T) {
+	pcp := testPrefixCredentialProvider()
+
+	cases := []struct {
+		method   string
+		path     string
+		header   string
+		expected string
+	}{
+		{"GET", "/deeper/team-a/file", "X-BlobStore-Read-Acl", "a-read"},
+		{"POST", "/deeper/team-a/file", "X-BlobStore-Write-Acl", "a-write"},
+		{"GET", "/deeper/team-a/private/file", "X-BlobStore-Read-Acl", "private-read"},
+		{"DELETE", "/deeper/team-a/private/nested/file", "X-BlobStore-Write-Acl", "private-write"},
+		{"GET", "/deeper/team-a/privateer", "X-BlobStore-Read-Acl", "a-read"},
+		{"GET", "/deeper/team-ab/file", "X-BlobStore-Read-Acl", "default-read"},
+		{"GET", "/deeper/team-b/file", "X-BlobStore-Read-Acl", "b-read"},
+		{"GET", "/deeper/_dir/team-a", "X-BlobStore-Read-Acl", "a-read"},
+		{"GET", "/deeper/_dir/team-b/", "X-BlobStore-Read-Acl", "b-read"},
+		{"GET", "/deeper/_dir/team-b", "X-BlobStore-Read-Acl", "b-read"},
+		{"GET", "/deeper/_dir/team-a/private", "X-BlobStore-Read-Acl", "private-read"},
+		{"GET", "/deeper/_dir/team-bb", "X-BlobStore-Read-Acl", "default-read"},
+		{"GET", "/deeper/_dir/", "X-BlobStore-Read-Acl", "default-read"},
+		{"GET", "/deeper/other", "X-BlobStore-Read-Acl", "default-read"},
+	}
+
+	for _, c := range cases {
+		request, err := http.NewRequest(c.method, "https://example.org"+c.path, nil)
+		assert.Nil(t, err)
+
+		err = pcp.AuthorizeRequest(request)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, request.Header.Get(c.header), c.path)
+	}
+}
+
+func TestPrefixCredentialProviderNoDefault(t *testing.T) {
+	pcp := testPrefixCredentialProvider()
+	pcp.Default = nil
+
+	request, err := http.NewRequest("GET", "https://example.org/deeper/other", nil)
+	assert.Nil(t, err)
+
+	err = pcp.AuthorizeRequest(request)
+	assert.Nil(t, err)
+	assert.False(t, HasReadAclHeader(request))
+}
+
+func TestPrefixCredentialProviderExplain(t *testing.T) {
+	pcp := testPrefixCredentialProvider()
+
+	request, err := http.NewRequest("GET", "https://example.org/deeper/team-a/private/file", nil)
+	assert.Nil(t, err)
+
+	sources, err := ExplainAuthorization(NewCredentialProviderChain(pcp), request)
+	assert.Nil(t, err)
+	assert.Equal(t, []CredentialSource{
+		{"X-Blobstore-Read-Acl", "private-read", "direct credentials, for prefix /team-a/private/"},
+	}, sources)
+
+	request, err = http.NewRequest("GET", "https://example.org/deeper/other", nil)
+	assert.Nil(t, err)
+
+	sources, err = ExplainAuthorization(pcp, request)
+	assert.Nil(t, err)
+	assert.Equal(t, []CredentialSource{
+		{"X-Blobstore-Read-Acl", "default-read", "direct credentials"},
+	}, sources)
+}
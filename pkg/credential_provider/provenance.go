@@ -0,0 +1,71 @@
+package credential_provider
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// A header added to a request, and the provider that added it.
+type CredentialSource struct {
+	Header   string
+	Value    string
+	Provider string
+}
+
+// Providers can implement fmt.Stringer to describe where their credentials
+// come from.
+func DescribeCredentialProvider(provider ICredentialProvider) string {
+	if stringer, ok := provider.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	return fmt.Sprintf("%T", provider)
+}
+
+// Authorizes the request, reporting where each header came from.
+// Chains report the individual providers that supplied headers, rather than
+// the chain itself, and prefix providers report the provider they picked for
+// the request.
+func ExplainAuthorization(provider ICredentialProvider, request *http.Request) ([]CredentialSource, error) {
+	switch p := provider.(type) {
+	case *CredentialProviderChain:
+		return p.AuthorizeRequestWithProvenance(request)
+	case *PrefixCredentialProvider:
+		selected, prefix := p.ProviderFor(request)
+		if selected == nil {
+			return []CredentialSource{}, nil
+		}
+
+		sources, err := ExplainAuthorization(selected, request)
+		if prefix != "" {
+			for i := range sources {
+				sources[i].Provider = fmt.Sprintf("%s, for prefix %s", sources[i].Provider, prefix)
+			}
+		}
+		return sources, err
+	}
+
+	return authorizeRequestWithProvenance(provider, request)
+}
+
+func authorizeRequestWithProvenance(provider ICredentialProvider, request *http.Request) ([]CredentialSource, error) {
+	before := request.Header.Clone()
+	err := provider.AuthorizeRequest(request)
+
+	sources := []CredentialSource{}
+	description := DescribeCredentialProvider(provider)
+	for header, values := range request.Header {
+		if _, ok := before[header]; ok || len(values) == 0 {
+			continue
+		}
+
+		sources = append(sources, CredentialSource{header, values[0], description})
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Header < sources[j].Header
+	})
+
+	return sources, err
+}
@@ -0,0 +1,77 @@
+package credential_provider
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+type undescribedCredentialProvider struct{}
+
+func (ucp *undescribedCredentialProvider) AuthorizeRequest(request *http.Request) error {
+	request.Header.Add(HttpRequestReadAclHeader, "xyz")
+	return nil
+}
+
+func TestDescribeCredentialProvider(t *testing.T) {
+	assert.Equal(t, "direct credentials", DescribeCredentialProvider(&DirectCredentialProvider{}))
+	assert.Equal(t, "environment variables A and B", DescribeCredentialProvider(&EnvironmentCredentialProvider{"A", "B"}))
+	assert.Equal(t, "credentials file /some/file (profile staging)", DescribeCredentialProvider(&FileCredentialProvider{Path: "/some/file", Profile: "staging"}))
+	assert.Equal(t, "credential command vault read", DescribeCredentialProvider(NewExecCredentialProvider("vault", "read")))
+	assert.Equal(t, "signing key key", DescribeCredentialProvider(&SigningCredentialProvider{"key", "secret"}))
+	assert.Equal(t, "*credential_provider.undescribedCredentialProvider", DescribeCredentialProvider(&undescribedCredentialProvider{}))
+}
+
+func TestExplainAuthorizationChain(t *testing.T) {
+	os.Setenv("TEST_READ_ACL", "abc")
+	defer os.Unsetenv("TEST_READ_ACL")
+
+	chain := NewCredentialProviderChain(
+		&EnvironmentCredentialProvider{"TEST_READ_ACL", "TEST_WRITE_ACL"},
+		&DirectCredentialProvider{"", ""},
+		&undescribedCredentialProvider{},
+	)
+
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	sources, err := ExplainAuthorization(chain, request)
+	assert.Nil(t, err)
+	assert.Equal(t, []CredentialSource{
+		{"X-Blobstore-Read-Acl", "abc", "environment variables TEST_READ_ACL and TEST_WRITE_ACL"},
+	}, sources)
+
+	request, err = http.NewRequest("POST", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	sources, err = ExplainAuthorization(chain, request)
+	assert.Nil(t, err)
+	assert.Equal(t, []CredentialSource{
+		{"X-Blobstore-Write-Acl", "", "direct credentials"},
+	}, sources)
+}
+
+func TestExplainAuthorizationSingleProvider(t *testing.T) {
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	sources, err := ExplainAuthorization(&undescribedCredentialProvider{}, request)
+	assert.Nil(t, err)
+	assert.Equal(t, []CredentialSource{
+		{"X-Blobstore-Read-Acl", "xyz", "*credential_provider.undescribedCredentialProvider"},
+	}, sources)
+}
+
+func TestExplainAuthorizationError(t *testing.T) {
+	request, err := http.NewRequest("GET", "https://example.org", nil)
+	assert.Nil(t, err)
+
+	chain := NewCredentialProviderChain(&ExecCredentialProvider{})
+	sources, err := ExplainAuthorization(chain, request)
+	assert.Equal(t, "No credential command configured", err.Error())
+	assert.Equal(t, []CredentialSource{}, sources)
+}
@@ -0,0 +1,141 @@
+package credential_provider
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func testSigningKeys(keyId string) (string, bool) {
+	if keyId == "key" {
+		return "secret", true
+	}
+	return "", false
+}
+
+func signedTestRequest(t *testing.T, method, url, body string) *http.Request {
+	var request *http.Request
+	var err error
+	if body == "" {
+		request, err = http.NewRequest(method, url, nil)
+	} else {
+		request, err = http.NewRequest(method, url, strings.NewReader(body))
+	}
+	assert.Nil(t, err)
+
+	scp := SigningCredentialProvider{"key", "secret"}
+	assert.Nil(t, scp.AuthorizeRequest(request))
+
+	return request
+}
+
+func TestSigningCredentialProvider(t *testing.T) {
+	request := signedTestRequest(t, "POST", "https://example.org/path/to/file?b=2&a=1", "contents")
+
+	assert.Equal(t, "key", request.Header.Get("X-BlobStore-Key-Id"))
+	assert.Equal(t, hashBytes([]byte("contents")), request.Header.Get("X-BlobStore-Content-Sha256"))
+	assert.NotEqual(t, "", request.Header.Get("X-BlobStore-Signature"))
+	assert.False(t, HasReadAclHeader(request))
+	assert.False(t, HasWriteAclHeader(request))
+
+	date, err := time.Parse(SignatureDateFormat, request.Header.Get("X-BlobStore-Date"))
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), date, time.Minute)
+
+	expectedStringToSign := strings.Join([]string{
+		"BLOBSTORE-HMAC-SHA256",
+		"POST",
+		"/path/to/file",
+		"a=1&b=2",
+		request.Header.Get("X-BlobStore-Date"),
+		hashBytes([]byte("contents")),
+	}, "\n")
+	assert.Equal(t, expectedStringToSign, stringToSign(request))
+
+	// The body must still be sendable after being hashed.
+	body, err := ioutil.ReadAll(request.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(body))
+}
+
+func TestSigningCredentialProviderStreamedBody(t *testing.T) {
+	request, err := http.NewRequest("POST", "https://example.org/file", bufio.NewReader(strings.NewReader("contents")))
+	assert.Nil(t, err)
+
+	scp := SigningCredentialProvider{"key", "secret"}
+	assert.Nil(t, scp.AuthorizeRequest(request))
+	assert.Equal(t, hashBytes([]byte("contents")), request.Header.Get("X-BlobStore-Content-Sha256"))
+	assert.Equal(t, int64(8), request.ContentLength)
+
+	assert.Nil(t, VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute))
+
+	body, err := ioutil.ReadAll(request.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(body))
+}
+
+func TestVerifySignedRequestUnsignedPayload(t *testing.T) {
+	request := signedTestRequest(t, "POST", "https://example.org/file", "replaced")
+	request.Header.Set("X-BlobStore-Content-Sha256", "UNSIGNED-PAYLOAD")
+	request.Header.Set("X-BlobStore-Signature", computeSignature("secret", request))
+
+	err := VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute)
+	assert.Equal(t, "Request body does not match its signed hash", err.Error())
+}
+
+func TestVerifySignedRequest(t *testing.T) {
+	request := signedTestRequest(t, "POST", "https://example.org/file", "contents")
+	assert.Nil(t, VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute))
+
+	body, err := ioutil.ReadAll(request.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(body))
+
+	request = signedTestRequest(t, "GET", "https://example.org/file", "")
+	assert.Equal(t, hashBytes([]byte{}), request.Header.Get("X-BlobStore-Content-Sha256"))
+	assert.Nil(t, VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute))
+}
+
+func TestVerifySignedRequestFailures(t *testing.T) {
+	request, err := http.NewRequest("GET", "https://example.org/file", nil)
+	assert.Nil(t, err)
+	err = VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute)
+	assert.True(t, errors.Is(err, ErrRequestNotSigned))
+
+	request = signedTestRequest(t, "GET", "https://example.org/file", "")
+	request.Header.Set(HttpRequestKeyIdHeader, "other")
+	err = VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute)
+	assert.Equal(t, "Unknown signing key other", err.Error())
+
+	request = signedTestRequest(t, "GET", "https://example.org/file", "")
+	err = VerifySignedRequest(request, testSigningKeys, time.Now().Add(2*time.Minute), time.Minute)
+	assert.Equal(t, "Request date "+request.Header.Get(HttpRequestDateHeader)+" is outside of the allowed clock skew", err.Error())
+
+	request = signedTestRequest(t, "GET", "https://example.org/file", "")
+	err = VerifySignedRequest(request, testSigningKeys, time.Now().Add(-2*time.Minute), time.Minute)
+	assert.NotNil(t, err)
+
+	request = signedTestRequest(t, "GET", "https://example.org/file", "")
+	request.URL.Path = "/other"
+	err = VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute)
+	assert.Equal(t, "Request signature does not match", err.Error())
+
+	request = signedTestRequest(t, "GET", "https://example.org/file", "")
+	request.Method = "DELETE"
+	err = VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute)
+	assert.Equal(t, "Request signature does not match", err.Error())
+
+	request = signedTestRequest(t, "POST", "https://example.org/file", "contents")
+	request.Body = ioutil.NopCloser(bytes.NewReader([]byte("tampered")))
+	err = VerifySignedRequest(request, testSigningKeys, time.Now(), time.Minute)
+	assert.Equal(t, "Request body does not match its signed hash", err.Error())
+}
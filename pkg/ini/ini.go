@@ -0,0 +1,151 @@
+// Package ini reads and writes the small INI dialect used by the blob CLI's
+// config and credentials files.
+//
+// Files are made up of [section] headers followed by "key = value" lines.
+// Lines starting with "#" or ";" are comments. Keys that appear before any
+// section header are ignored.
+package ini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Section struct {
+	Name   string
+	keys   []string
+	values map[string]string
+}
+
+type File struct {
+	sections []*Section
+}
+
+func NewFile() *File {
+	return &File{}
+}
+
+func newSection(name string) *Section {
+	return &Section{
+		Name:   name,
+		values: map[string]string{},
+	}
+}
+
+func Parse(r io.Reader) (*File, error) {
+	file := NewFile()
+
+	var section *Section
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("Invalid section header on line %d", lineNumber)
+			}
+
+			section = file.AddSection(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+
+		equals := strings.Index(line, "=")
+		if equals == -1 {
+			return nil, fmt.Errorf("Expected key = value on line %d", lineNumber)
+		}
+
+		if section == nil {
+			continue
+		}
+
+		key := strings.TrimSpace(line[:equals])
+		value := strings.TrimSpace(line[equals+1:])
+		section.Set(key, value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (f *File) Sections() []*Section {
+	return f.sections
+}
+
+func (f *File) Section(name string) (*Section, bool) {
+	for _, section := range f.sections {
+		if section.Name == name {
+			return section, true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the named section, creating it if it doesn't exist yet.
+// Repeated section headers are merged into a single section.
+func (f *File) AddSection(name string) *Section {
+	if section, ok := f.Section(name); ok {
+		return section
+	}
+
+	section := newSection(name)
+	f.sections = append(f.sections, section)
+	return section
+}
+
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for i, section := range f.sections {
+		if i != 0 {
+			n, err := fmt.Fprintln(w)
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+
+		n, err := fmt.Fprintf(w, "[%s]\n", section.Name)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+
+		for _, key := range section.keys {
+			n, err := fmt.Fprintf(w, "%s = %s\n", key, section.values[key])
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+func (s *Section) Keys() []string {
+	return s.keys
+}
+
+func (s *Section) Get(key string) (string, bool) {
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *Section) Set(key, value string) {
+	if _, ok := s.values[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+
+	s.values[key] = value
+}
@@ -0,0 +1,89 @@
+package ini
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	contents := `
+ignored = before any section
+
+# A comment
+[default]
+endpoint = https://blob.example.com
+timeout=10s
+
+; Another comment
+[staging]
+endpoint = https://staging.example.com/base = with equals
+
+[default]
+retries = 5
+`
+
+	file, err := Parse(strings.NewReader(contents))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(file.Sections()))
+
+	section, ok := file.Section("default")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"endpoint", "timeout", "retries"}, section.Keys())
+
+	value, ok := section.Get("timeout")
+	assert.True(t, ok)
+	assert.Equal(t, "10s", value)
+
+	section, ok = file.Section("staging")
+	assert.True(t, ok)
+
+	value, ok = section.Get("endpoint")
+	assert.True(t, ok)
+	assert.Equal(t, "https://staging.example.com/base = with equals", value)
+
+	_, ok = section.Get("timeout")
+	assert.False(t, ok)
+
+	_, ok = file.Section("missing")
+	assert.False(t, ok)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("[default\n"))
+	assert.Equal(t, "Invalid section header on line 1", err.Error())
+
+	_, err = Parse(strings.NewReader("[default]\nendpoint\n"))
+	assert.Equal(t, "Expected key = value on line 2", err.Error())
+}
+
+func TestWriteTo(t *testing.T) {
+	file := NewFile()
+	file.AddSection("default").Set("endpoint", "https://blob.example.com")
+
+	staging := file.AddSection("staging")
+	staging.Set("endpoint", "https://staging.example.com")
+	staging.Set("retries", "1")
+	staging.Set("endpoint", "https://other.example.com")
+
+	var buffer bytes.Buffer
+	_, err := file.WriteTo(&buffer)
+	assert.Nil(t, err)
+
+	expected := `[default]
+endpoint = https://blob.example.com
+
+[staging]
+endpoint = https://other.example.com
+retries = 1
+`
+	assert.Equal(t, expected, buffer.String())
+
+	reparsed, err := Parse(&buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reparsed.Sections()))
+}